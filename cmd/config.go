@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	// tzdata embeds the timezone database, so the configured timezone loads where the system has none.
+	_ "time/tzdata"
+
+	"github.com/unknown/authorizer/internal/core/service"
+)
+
+const (
+	// defaultIdempotencyRetention is how long the result of a transaction is kept for the retries with its idempotency
+	// key.
+	defaultIdempotencyRetention = 24 * time.Hour
+)
+
+type (
+	Config struct {
+		HighFrequencySmallInterval HighFrequencySmallIntervalConfig `json:"high-frequency-small-interval"`
+		DoubleTransaction          DoubleTransactionConfig          `json:"double-transaction"`
+		TooManyDeclines            TooManyDeclinesConfig            `json:"too-many-declines"`
+		Hold                       HoldConfig                       `json:"hold"`
+		Idempotency                IdempotencyConfig                `json:"idempotency"`
+		History                    HistoryConfig                    `json:"history"`
+		MerchantLimits             map[string]SpendingLimitConfig   `json:"merchant-limits"`
+		MCCLimits                  map[string]SpendingLimitConfig   `json:"mcc-limits"`
+		TransactionTime            TransactionTimeConfig            `json:"transaction-time"`
+		// TimeBasis tells whether the rule windows are checked at the event time, sent with the transactions, or at the
+		// processing time.
+		TimeBasis string `json:"time-basis"`
+		// Timezone is the IANA name of the location where calendar days and months start, like "America/Sao_Paulo".
+		Timezone string `json:"timezone"`
+	}
+
+	// HighFrequencySmallIntervalConfig counts the declined transactions toward the max transactions when CountAttempts
+	// is set.
+	HighFrequencySmallIntervalConfig struct {
+		Window          Duration `json:"window"`
+		MaxTransactions int      `json:"max-transactions"`
+		CountAttempts   bool     `json:"count-attempts"`
+	}
+
+	DoubleTransactionConfig struct {
+		Window Duration `json:"window"`
+		Match  []string `json:"match"`
+	}
+
+	// TooManyDeclinesConfig locks the card after MaxDeclines declined transactions within the window, zero never locks.
+	TooManyDeclinesConfig struct {
+		Window      Duration `json:"window"`
+		MaxDeclines int      `json:"max-declines"`
+	}
+
+	// TransactionTimeConfig bounds how far in the future and in the past a transaction time can be, zero is unbounded.
+	TransactionTimeConfig struct {
+		ClockSkew Duration `json:"clock-skew"`
+		MaxAge    Duration `json:"max-age"`
+	}
+
+	HoldConfig struct {
+		TTL Duration `json:"ttl"`
+	}
+
+	IdempotencyConfig struct {
+		Retention Duration `json:"retention"`
+	}
+
+	// HistoryConfig keeps the ledger events and decisions of an account recorded within the Retention before its latest
+	// event, a zero Retention keeping them all.
+	HistoryConfig struct {
+		Retention Duration `json:"retention"`
+	}
+
+	SpendingLimitConfig struct {
+		PerTransaction int `json:"per-transaction"`
+		Daily          int `json:"daily"`
+		Monthly        int `json:"monthly"`
+	}
+
+	// Duration is a time.Duration written in JSON as a string, like "2m" or "90s".
+	Duration time.Duration
+)
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+func defaultConfig() Config {
+	rulesConfig := service.DefaultRulesConfig()
+	return Config{
+		HighFrequencySmallInterval: HighFrequencySmallIntervalConfig{
+			Window:          Duration(rulesConfig.HighFrequencyWindow),
+			MaxTransactions: rulesConfig.HighFrequencyMaxTransactions,
+			CountAttempts:   rulesConfig.HighFrequencyCountAttempts,
+		},
+		DoubleTransaction: DoubleTransactionConfig{
+			Window: Duration(rulesConfig.DoubleTransactionWindow),
+			Match:  rulesConfig.DoubleTransactionMatch,
+		},
+		TooManyDeclines: TooManyDeclinesConfig{
+			Window:      Duration(rulesConfig.TooManyDeclinesWindow),
+			MaxDeclines: rulesConfig.TooManyDeclinesMax,
+		},
+		Hold: HoldConfig{
+			TTL: Duration(service.DefaultHoldTTL),
+		},
+		Idempotency: IdempotencyConfig{
+			Retention: Duration(defaultIdempotencyRetention),
+		},
+		TimeBasis: service.TimeBasisEvent,
+		Timezone:  rulesConfig.Location.String(),
+	}
+}
+
+// loadConfig reads the config file at path over the default config, an empty path returns the default config.
+func loadConfig(path string) (Config, error) {
+	config := defaultConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, err
+	}
+	if config.Hold.TTL <= 0 {
+		return Config{}, errors.New("hold ttl must be positive")
+	}
+	if config.Idempotency.Retention <= 0 {
+		return Config{}, errors.New("idempotency retention must be positive")
+	}
+	if config.History.Retention < 0 {
+		return Config{}, errors.New("history retention must not be negative")
+	}
+	if config.TimeBasis != service.TimeBasisEvent && config.TimeBasis != service.TimeBasisProcessing {
+		return Config{}, fmt.Errorf("unknown time basis %q", config.TimeBasis)
+	}
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		return Config{}, fmt.Errorf("invalid timezone: %w", err)
+	}
+	return config, nil
+}
+
+// rulesConfig returns the config of the rules, bounding the transaction times around the processing time told by the
+// clock.
+func (c Config) rulesConfig(clock service.Clock) service.RulesConfig {
+	return service.RulesConfig{
+		HighFrequencyWindow:          time.Duration(c.HighFrequencySmallInterval.Window),
+		HighFrequencyMaxTransactions: c.HighFrequencySmallInterval.MaxTransactions,
+		HighFrequencyCountAttempts:   c.HighFrequencySmallInterval.CountAttempts,
+		TooManyDeclinesWindow:        time.Duration(c.TooManyDeclines.Window),
+		TooManyDeclinesMax:           c.TooManyDeclines.MaxDeclines,
+		DoubleTransactionWindow:      time.Duration(c.DoubleTransaction.Window),
+		DoubleTransactionMatch:       c.DoubleTransaction.Match,
+		MerchantLimits:               spendingLimits(c.MerchantLimits),
+		MCCLimits:                    spendingLimits(c.MCCLimits),
+		Location:                     c.location(),
+		ClockSkew:                    time.Duration(c.TransactionTime.ClockSkew),
+		MaxTransactionAge:            time.Duration(c.TransactionTime.MaxAge),
+		Clock:                        clock,
+	}
+}
+
+// location returns the location of the config timezone, which loadConfig already validated.
+func (c Config) location() *time.Location {
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+func spendingLimits(configs map[string]SpendingLimitConfig) map[string]service.SpendingLimit {
+	if len(configs) == 0 {
+		return nil
+	}
+
+	limits := make(map[string]service.SpendingLimit, len(configs))
+	for key, config := range configs {
+		limits[key] = service.SpendingLimit{
+			PerTransaction: config.PerTransaction,
+			Daily:          config.Daily,
+			Monthly:        config.Monthly,
+		}
+	}
+	return limits
+}
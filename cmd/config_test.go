@@ -0,0 +1,237 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/service"
+)
+
+func Test_loadConfig(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should return default config when path is empty": func(t *testing.T) {
+			// 	when
+			config, err := loadConfig("")
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, service.DefaultRulesConfig(), config.rulesConfig(service.SystemClock{}))
+		},
+		"should override default config with given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"high-frequency-small-interval": {"window": "90s"}, "double-transaction": {"match": ["merchant"]}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			wantConfig := service.RulesConfig{
+				HighFrequencyWindow:          90 * time.Second,
+				HighFrequencyMaxTransactions: 3,
+				TooManyDeclinesWindow:        10 * time.Minute,
+				DoubleTransactionWindow:      2 * time.Minute,
+				DoubleTransactionMatch:       []string{service.MatchMerchant},
+				Location:                     time.UTC,
+				Clock:                        service.SystemClock{},
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, wantConfig, config.rulesConfig(service.SystemClock{}))
+		},
+		"should read spending limits from given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"merchant-limits": {"casino": {"per-transaction": 200}}, "mcc-limits": {"7995": {"daily": 500, "monthly": 2000}}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]service.SpendingLimit{"casino": {PerTransaction: 200}}, config.rulesConfig(service.SystemClock{}).MerchantLimits)
+			assert.Equal(t, map[string]service.SpendingLimit{"7995": {Daily: 500, Monthly: 2000}}, config.rulesConfig(service.SystemClock{}).MCCLimits)
+		},
+		"should read timezone from given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"timezone": "America/Sao_Paulo"}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, "America/Sao_Paulo", config.rulesConfig(service.SystemClock{}).Location.String())
+		},
+		"should read transaction time tolerances from given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"transaction-time": {"clock-skew": "5m", "max-age": "24h"}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, 5*time.Minute, config.rulesConfig(service.SystemClock{}).ClockSkew)
+			assert.Equal(t, 24*time.Hour, config.rulesConfig(service.SystemClock{}).MaxTransactionAge)
+		},
+		"should read attempts counting and too many declines from given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"high-frequency-small-interval": {"count-attempts": true}, "too-many-declines": {"window": "1h", "max-declines": 5}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.True(t, config.rulesConfig(service.SystemClock{}).HighFrequencyCountAttempts)
+			assert.Equal(t, time.Hour, config.rulesConfig(service.SystemClock{}).TooManyDeclinesWindow)
+			assert.Equal(t, 5, config.rulesConfig(service.SystemClock{}).TooManyDeclinesMax)
+		},
+		"should read time basis from given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"time-basis": "processing"}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, service.TimeBasisProcessing, config.TimeBasis)
+		},
+		"should return error when time basis is unknown": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"time-basis": "wall"}`)
+
+			// 	when
+			_, err := loadConfig(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, `unknown time basis "wall"`)
+		},
+		"should return error when timezone is unknown": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"timezone": "Mars/Olympus_Mons"}`)
+
+			// 	when
+			_, err := loadConfig(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "invalid timezone: unknown time zone Mars/Olympus_Mons")
+		},
+		"should override default hold ttl with given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"hold": {"ttl": "24h"}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, Duration(24*time.Hour), config.Hold.TTL)
+			assert.Equal(t, service.DefaultRulesConfig(), config.rulesConfig(service.SystemClock{}))
+		},
+		"should return error when hold ttl is not positive": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"hold": {"ttl": "0s"}}`)
+
+			// 	when
+			_, err := loadConfig(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "hold ttl must be positive")
+		},
+		"should override default idempotency retention with given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"idempotency": {"retention": "1h"}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, Duration(time.Hour), config.Idempotency.Retention)
+		},
+		"should return error when idempotency retention is not positive": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"idempotency": {"retention": "0s"}}`)
+
+			// 	when
+			_, err := loadConfig(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "idempotency retention must be positive")
+		},
+		"should override default history retention with given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"history": {"retention": "720h"}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, Duration(720*time.Hour), config.History.Retention)
+		},
+		"should keep whole history by default": func(t *testing.T) {
+			// 	when
+			config, err := loadConfig("")
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Zero(t, config.History.Retention)
+		},
+		"should accept zero history retention": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"history": {"retention": "0s"}}`)
+
+			// 	when
+			config, err := loadConfig(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Zero(t, config.History.Retention)
+		},
+		"should return error when history retention is negative": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"history": {"retention": "-1h"}}`)
+
+			// 	when
+			_, err := loadConfig(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "history retention must not be negative")
+		},
+		"should return error when duration is invalid": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"double-transaction": {"window": "two minutes"}}`)
+
+			// 	when
+			_, err := loadConfig(givenPath)
+
+			// 	then
+			assert.Error(t, err)
+		},
+		"should return error when file does not exist": func(t *testing.T) {
+			// 	when
+			_, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+
+			// 	then
+			assert.Error(t, err)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+	"github.com/unknown/authorizer/internal/core/service"
+)
+
+// parseDecisionFilter returns the filter of the decision history, from and to being RFC 3339 times. An empty value
+// leaves its field unset.
+func parseDecisionFilter(outcome string, violation string, from string, to string) (domain.DecisionFilter, error) {
+	if outcome != "" && outcome != domain.OutcomeAuthorized && outcome != domain.OutcomeRejected {
+		return domain.DecisionFilter{}, fmt.Errorf("unknown outcome %q", outcome)
+	}
+	filter := domain.DecisionFilter{Outcome: outcome, Violation: violation}
+
+	var err error
+	if from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return domain.DecisionFilter{}, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return domain.DecisionFilter{}, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+// printHistory writes the decisions on the transactions of the account matching the filter, one JSON per line, or the
+// output of the violation when the account is not initialized.
+func printHistory(w io.Writer, transactionService service.TransactionService, accountID string, filter domain.DecisionFilter) error {
+	decisions, err := transactionService.GetDecisions(accountID, filter)
+	if errors.Is(err, domain.ErrInternal) {
+		return err
+	}
+	if err != nil {
+		_, err = fmt.Fprintln(w, parseOutput(domain.Account{}, []error{err}))
+		return err
+	}
+
+	for _, decision := range decisions {
+		data, err := json.Marshal(decision)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+	"github.com/unknown/authorizer/internal/core/service"
+	"github.com/unknown/authorizer/internal/repository"
+)
+
+func Test_parseDecisionFilter(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should return filter with every given field": func(t *testing.T) {
+			// 	when
+			filter, err := parseDecisionFilter("rejected", "insufficient-limit", "2019-02-13T10:00:00Z", "2019-02-14T10:00:00Z")
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, domain.DecisionFilter{
+				Outcome:   domain.OutcomeRejected,
+				Violation: "insufficient-limit",
+				From:      time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+				To:        time.Date(2019, 02, 14, 10, 0, 0, 0, time.UTC),
+			}, filter)
+		},
+		"should return empty filter when no field is given": func(t *testing.T) {
+			// 	when
+			filter, err := parseDecisionFilter("", "", "", "")
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, domain.DecisionFilter{}, filter)
+		},
+		"should return error when outcome is unknown": func(t *testing.T) {
+			// 	when
+			_, err := parseDecisionFilter("declined", "", "", "")
+
+			// 	then
+			assert.EqualError(t, err, `unknown outcome "declined"`)
+		},
+		"should return error when time is invalid": func(t *testing.T) {
+			// 	when
+			_, err := parseDecisionFilter("", "", "yesterday", "")
+
+			// 	then
+			assert.Error(t, err)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
+
+func Test_printHistory(t *testing.T) {
+	givenTransactions := []domain.Transaction{
+		{AccountID: "1", Merchant: "Burger King", Money: domain.Money{Amount: 20}, CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)},
+		{AccountID: "1", Merchant: "Habbib's", Money: domain.Money{Amount: 120}, CreatedAt: time.Date(2019, 02, 13, 10, 1, 0, 0, time.UTC)},
+	}
+
+	testCases := map[string]func(*testing.T, service.AccountService, service.TransactionService){
+		"should print decisions of account one per line": func(t *testing.T, accountService service.AccountService, transactionService service.TransactionService) {
+			// 	given
+			_, err := accountService.CreateAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			for _, transaction := range givenTransactions {
+				transactionService.AuthorizeTransaction(transaction)
+			}
+			output := bytes.Buffer{}
+
+			// 	when
+			err = printHistory(&output, transactionService, "1", domain.DecisionFilter{})
+
+			// 	then
+			assert.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			assert.Len(t, lines, 2)
+			decisions := make([]domain.Decision, len(lines))
+			for i, line := range lines {
+				assert.NoError(t, json.Unmarshal([]byte(line), &decisions[i]))
+			}
+			assert.Equal(t, domain.OutcomeAuthorized, decisions[0].Outcome)
+			assert.Equal(t, givenTransactions[0], decisions[0].Transaction)
+			assert.Equal(t, domain.OutcomeRejected, decisions[1].Outcome)
+			assert.Equal(t, []string{"insufficient-limit"}, decisions[1].Violations)
+			assert.Equal(t, 80, decisions[1].Account.AvailableLimit)
+		},
+		"should print violation when account not initialized": func(t *testing.T, _ service.AccountService, transactionService service.TransactionService) {
+			// 	given
+			output := bytes.Buffer{}
+
+			// 	when
+			err := printHistory(&output, transactionService, "1", domain.DecisionFilter{})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, `{"account":{},"violations":["account-not-initialized"]}`+"\n", output.String())
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			memoryRepository := repository.NewMemoryRepository()
+			accountService := service.NewAccountService(&memoryRepository)
+			transactionService := service.NewTransactionService(&memoryRepository, service.DefaultRules(), service.SystemClock{}, service.TimeBasisEvent, nil)
+
+			run(t, accountService, transactionService)
+		})
+	}
+}
@@ -1,27 +1,111 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"os"
+	"errors"
 
 	"github.com/unknown/authorizer/internal/core/domain"
 )
 
+const (
+	operationAccount     = "account"
+	operationTransaction = "transaction"
+	operationReversal    = "reversal"
+	operationHold        = "hold"
+	operationCapture     = "capture"
+	operationRelease     = "release"
+	operationCard        = "card"
+	operationLimitChange = "account-limit-change"
+	operationSimulate    = "simulate"
+)
+
+var (
+	errInvalidInput       = errors.New("invalid-input")
+	errUnknownOperation   = errors.New("unknown-operation")
+	errMultipleOperations = errors.New("multiple-operations")
+)
+
+// Input is an operation line, Operation is the top-level key the line has, so an operation with only zero values is
+// still told apart from the others.
 type Input struct {
+	Operation   string             `json:"-"`
 	Transaction domain.Transaction `json:"transaction"`
 	Account     domain.Account     `json:"account"`
+	Reversal    domain.Reversal    `json:"reversal"`
+	Hold        domain.Hold        `json:"hold"`
+	Capture     domain.Capture     `json:"capture"`
+	Release     domain.Release     `json:"release"`
+	Card        domain.CardChange  `json:"card"`
+	LimitChange domain.LimitChange `json:"account-limit-change"`
+	// Simulation is a transaction checked as if it were authorized, without being recorded.
+	Simulation domain.Transaction `json:"simulate"`
 }
 
-func (o Input) isCreateAccount() bool {
-	return o.Account != domain.Account{}
+func (o *Input) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	operations := []struct {
+		name  string
+		value interface{}
+	}{
+		{operationAccount, &o.Account},
+		{operationTransaction, &o.Transaction},
+		{operationReversal, &o.Reversal},
+		{operationHold, &o.Hold},
+		{operationCapture, &o.Capture},
+		{operationRelease, &o.Release},
+		{operationCard, &o.Card},
+		{operationLimitChange, &o.LimitChange},
+		{operationSimulate, &o.Simulation},
+	}
+	for _, operation := range operations {
+		field, ok := fields[operation.name]
+		if !ok {
+			continue
+		}
+		if o.Operation != "" {
+			return errMultipleOperations
+		}
+		if err := json.Unmarshal(field, operation.value); err != nil {
+			return err
+		}
+		o.Operation = operation.name
+	}
+	return nil
 }
 
-func parseInput(JSON string) Input {
+// parseInput returns the operation in the JSON line, errUnknownOperation when the line has none of the known ones.
+func parseInput(JSON string) (Input, error) {
 	operation := Input{}
 	if err := json.Unmarshal([]byte(JSON), &operation); err != nil {
-		fmt.Println("failed to parse operation", err)
-		os.Exit(1)
+		return Input{}, err
+	}
+	if operation.Operation == "" {
+		return Input{}, errUnknownOperation
+	}
+	return operation, nil
+}
+
+// identify gives the transaction of the input an id when it has none, so it can be reversed later: its idempotency key
+// when it has one, so a retry keeps the id of the transaction it retries, otherwise a random one.
+func identify(input Input) (Input, error) {
+	if input.Operation != operationTransaction || input.Transaction.ID != "" {
+		return input, nil
+	}
+	if input.Transaction.IdempotencyKey != "" {
+		input.Transaction.ID = input.Transaction.IdempotencyKey
+		return input, nil
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Input{}, err
 	}
-	return operation
+	input.Transaction.ID = hex.EncodeToString(id)
+	return input, nil
 }
@@ -13,11 +13,13 @@ func Test_parseOperation(t *testing.T) {
 		name          string
 		givenJSON     string
 		wantOperation Input
+		wantErr       string
 	}{
 		{
 			name:      "should parse account operation",
 			givenJSON: `{"account": {"active-card": true, "available-limit": 100}}`,
 			wantOperation: Input{
+				Operation: operationAccount,
 				Account: domain.Account{
 					ActiveCard:     true,
 					AvailableLimit: 100,
@@ -28,19 +30,179 @@ func Test_parseOperation(t *testing.T) {
 			name:      "should parse transaction operation",
 			givenJSON: `{"transaction": {"merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`,
 			wantOperation: Input{
+				Operation: operationTransaction,
 				Transaction: domain.Transaction{
-					Amount:    20,
+					Money:     domain.Money{Amount: 20},
 					Merchant:  "Burger King",
 					CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
 				},
 			},
 		},
+		{
+			name:      "should parse operations with account id",
+			givenJSON: `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`,
+			wantOperation: Input{
+				Operation: operationTransaction,
+				Transaction: domain.Transaction{
+					AccountID: "1",
+					Money:     domain.Money{Amount: 20},
+					Merchant:  "Burger King",
+					CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:      "should parse reversal operation",
+			givenJSON: `{"reversal": {"account-id": "1", "transaction-id": "t1", "time": "2019-02-13T10:05:00.000Z"}}`,
+			wantOperation: Input{
+				Operation: operationReversal,
+				Reversal: domain.Reversal{
+					AccountID:     "1",
+					TransactionID: "t1",
+					CreatedAt:     time.Date(2019, 02, 13, 10, 5, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:      "should parse hold operation",
+			givenJSON: `{"hold": {"id": "h1", "account-id": "1", "merchant": "hotel", "amount": 60, "time": "2019-02-13T10:00:00.000Z"}}`,
+			wantOperation: Input{
+				Operation: operationHold,
+				Hold: domain.Hold{
+					ID:        "h1",
+					AccountID: "1",
+					Money:     domain.Money{Amount: 60},
+					Merchant:  "hotel",
+					CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:      "should parse capture operation",
+			givenJSON: `{"capture": {"account-id": "1", "hold-id": "h1", "amount": 45, "time": "2019-02-13T10:30:00.000Z"}}`,
+			wantOperation: Input{
+				Operation: operationCapture,
+				Capture: domain.Capture{
+					AccountID: "1",
+					HoldID:    "h1",
+					Money:     domain.Money{Amount: 45},
+					CreatedAt: time.Date(2019, 02, 13, 10, 30, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:      "should parse card operation",
+			givenJSON: `{"card": {"account-id": "1", "action": "block", "reason": "lost", "time": "2019-02-13T10:00:00.000Z"}}`,
+			wantOperation: Input{
+				Operation: operationCard,
+				Card: domain.CardChange{
+					AccountID: "1",
+					Action:    domain.CardBlock,
+					Reason:    "lost",
+					CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:      "should parse account limit change operation",
+			givenJSON: `{"account-limit-change": {"account-id": "1", "total-limit": 500, "time": "2019-02-13T10:00:00.000Z"}}`,
+			wantOperation: Input{
+				Operation: operationLimitChange,
+				LimitChange: domain.LimitChange{
+					AccountID:  "1",
+					TotalLimit: 500,
+					CreatedAt:  time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:      "should parse account operation with zero values",
+			givenJSON: `{"account": {"active-card": false, "available-limit": 0}}`,
+			wantOperation: Input{
+				Operation: operationAccount,
+			},
+		},
+		{
+			name:      "should return error when line has more than one operation",
+			givenJSON: `{"account": {"active-card": true}, "transaction": {"merchant": "Burger King", "amount": 20}}`,
+			wantErr:   "multiple-operations",
+		},
+		{
+			name:      "should return error when line is not json",
+			givenJSON: `{"transaction": {"merchant": "Burger King", "amount": 20`,
+			wantErr:   "unexpected end of JSON input",
+		},
+		{
+			name:      "should return error when line has no known operation",
+			givenJSON: `{"refund": {"merchant": "Burger King", "amount": 20}}`,
+			wantErr:   "unknown-operation",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			gotOperation := parseInput(test.givenJSON)
+			gotOperation, err := parseInput(test.givenJSON)
 
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
 			assert.Equal(t, test.wantOperation, gotOperation)
 		})
 	}
 }
+
+func Test_identify(t *testing.T) {
+	tests := map[string]func(*testing.T){
+		"should keep id of transaction": func(t *testing.T) {
+			// 	given
+			input := Input{Operation: operationTransaction, Transaction: domain.Transaction{ID: "t1", IdempotencyKey: "k1"}}
+
+			// 	when
+			got, err := identify(input)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, input, got)
+		},
+		"should use idempotency key as id of transaction without id": func(t *testing.T) {
+			// 	given
+			input := Input{Operation: operationTransaction, Transaction: domain.Transaction{IdempotencyKey: "k1"}}
+
+			// 	when
+			got, err := identify(input)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, "k1", got.Transaction.ID)
+		},
+		"should assign distinct ids to transactions without id": func(t *testing.T) {
+			// 	given
+			input := Input{Operation: operationTransaction}
+
+			// 	when
+			first, firstErr := identify(input)
+			second, secondErr := identify(input)
+
+			// 	then
+			assert.NoError(t, firstErr)
+			assert.NoError(t, secondErr)
+			assert.Len(t, first.Transaction.ID, 32)
+			assert.NotEqual(t, first.Transaction.ID, second.Transaction.ID)
+		},
+		"should not identify other operations": func(t *testing.T) {
+			// 	given
+			input := Input{Operation: operationSimulate}
+
+			// 	when
+			got, err := identify(input)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, input, got)
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, run)
+	}
+}
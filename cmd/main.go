@@ -1,32 +1,254 @@
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/unknown/authorizer/internal/core/domain"
 	"github.com/unknown/authorizer/internal/core/service"
 	"github.com/unknown/authorizer/internal/repository"
 )
 
+type Repository interface {
+	service.AccountRepository
+	service.TransactionRepository
+	service.HoldRepository
+}
+
+var (
+	configPath       string
+	ratesPath        string
+	dataDir          string
+	snapshotEvery    int
+	violationDetails bool
+	totalLimit       bool
+	transactionID    bool
+	strict           bool
+	maxLineSize      int
+
+	serveFlags = flag.NewFlagSet("serve", flag.ExitOnError)
+	serveAddr  = serveFlags.String("addr", ":8080", "address the HTTP server listens on")
+
+	historyFlags     = flag.NewFlagSet("history", flag.ExitOnError)
+	historyAccount   = historyFlags.String("account", "", "id of the account whose decisions are listed")
+	historyOutcome   = historyFlags.String("outcome", "", "lists only the decisions with the outcome, authorized or rejected")
+	historyViolation = historyFlags.String("violation", "", "lists only the decisions with the violation code")
+	historyFrom      = historyFlags.String("from", "", "lists only the decisions made from the RFC 3339 time on")
+	historyTo        = historyFlags.String("to", "", "lists only the decisions made before the RFC 3339 time")
+)
+
+func init() {
+	flag.BoolVar(&strict, "strict", false, "exit on the first input line that can not be parsed instead of reporting it")
+	for _, flags := range []*flag.FlagSet{flag.CommandLine, serveFlags} {
+		flags.IntVar(&maxLineSize, "max-line-size", defaultMaxLineSize, "max size in bytes of an input line or request body")
+	}
+	for _, flags := range []*flag.FlagSet{flag.CommandLine, serveFlags, historyFlags} {
+		flags.StringVar(&configPath, "config", "", "path to a JSON file tuning the authorization rules")
+		flags.StringVar(&ratesPath, "rates", "", "path to a JSON file with the exchange rates between currencies")
+		flags.StringVar(&dataDir, "data-dir", "", "directory where the state is persisted, kept only in memory when empty")
+		flags.IntVar(&snapshotEvery, "snapshot-every", 1000, "number of logged changes between snapshots of the persisted state")
+		flags.BoolVar(&violationDetails, "violation-details", false, "add the details of every violation to the output")
+		flags.BoolVar(&totalLimit, "total-limit", false, "add the total limit of the account to the output")
+		flags.BoolVar(&transactionID, "transaction-id", false, "add the id of the transaction to the output of a transaction")
+	}
+}
+
 func main() {
-	memoryRepository := repository.NewMemoryRepository()
-	accountService := service.NewAccountService(&memoryRepository)
-	transactionService := service.NewTransactionService(&memoryRepository, accountService)
-
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		input := parseInput(scanner.Text())
-
-		if input.isCreateAccount() {
-			account, err := accountService.CreateAccount(input.Account)
-			output := parseOutput(account, []error{err})
-			fmt.Println(output)
+	flag.Parse()
+
+	serve := flag.Arg(0) == "serve"
+	if serve {
+		serveFlags.Parse(flag.Args()[1:])
+	}
+	history := flag.Arg(0) == "history"
+	if history {
+		historyFlags.Parse(flag.Args()[1:])
+	}
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Println("failed to load config", err)
+		os.Exit(1)
+	}
+
+	rates, err := loadRates(ratesPath)
+	if err != nil {
+		fmt.Println("failed to load rates", err)
+		os.Exit(1)
+	}
+
+	clock := service.SystemClock{}
+	rules, err := service.NewRules(config.rulesConfig(clock))
+	if err != nil {
+		fmt.Println("invalid config", err)
+		os.Exit(1)
+	}
+
+	filter, err := parseDecisionFilter(*historyOutcome, *historyViolation, *historyFrom, *historyTo)
+	if history && err != nil {
+		fmt.Println("invalid history filter", err)
+		os.Exit(1)
+	}
+	if history && dataDir == "" {
+		fmt.Println("history needs a data dir")
+		os.Exit(1)
+	}
+
+	repository, closeRepository, err := openRepository(
+		history,
+		rules.Window(),
+		time.Duration(config.Idempotency.Retention),
+		time.Duration(config.History.Retention),
+		clock,
+	)
+	if err != nil {
+		fmt.Println("failed to open repository", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := closeRepository(); err != nil {
+			fmt.Println("failed to close repository", err)
+		}
+	}()
+
+	accountService := service.NewAccountService(repository)
+	transactionService := service.NewTransactionService(repository, rules, clock, config.TimeBasis, rates)
+	holdService := service.NewHoldService(repository, rules, clock, config.TimeBasis, time.Duration(config.Hold.TTL), rates)
+
+	if history {
+		if err := printHistory(os.Stdout, transactionService, *historyAccount, filter); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to print history", err)
+			closeRepository()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if serve {
+		fmt.Println("listening on", *serveAddr)
+		if err := http.ListenAndServe(*serveAddr, newServer(accountService, transactionService, holdService)); err != nil {
+			fmt.Println("failed to serve", err)
+			closeRepository()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if maxLineSize <= 0 {
+		fmt.Println("invalid max line size", maxLineSize)
+		closeRepository()
+		os.Exit(1)
+	}
+
+	reader := newLineReader(os.Stdin, maxLineSize)
+	for {
+		text, line, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, errLineTooLong) {
+			fmt.Fprintln(os.Stderr, "failed to read input", err)
+			closeRepository()
+			os.Exit(1)
+		}
+
+		input := Input{}
+		if err == nil {
+			input, err = parseInput(text)
+		}
+		if err == nil {
+			if input, err = identify(input); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to identify transaction", err)
+				closeRepository()
+				os.Exit(1)
+			}
+		}
+		if err != nil && strict {
+			fmt.Println("failed to parse operation", err)
+			closeRepository()
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Println(parseInputError(line, err))
 			continue
 		}
 
-		account, errs := transactionService.AuthorizeTransaction(input.Transaction)
-		output := parseOutput(account, errs)
-		fmt.Println(output)
+		var account domain.Account
+		var errs []error
+		switch input.Operation {
+		case operationAccount:
+			account, err = accountService.CreateAccount(input.Account)
+			errs = []error{err}
+		case operationCard:
+			account, errs = accountService.ChangeCard(input.Card)
+		case operationLimitChange:
+			account, errs = accountService.ChangeLimit(input.LimitChange)
+		case operationReversal:
+			account, errs = transactionService.ReverseTransaction(input.Reversal)
+		case operationHold:
+			account, errs = holdService.PlaceHold(input.Hold)
+		case operationCapture:
+			account, errs = holdService.CaptureHold(input.Capture)
+		case operationRelease:
+			account, errs = holdService.ReleaseHold(input.Release)
+		case operationTransaction:
+			account, errs = transactionService.AuthorizeTransaction(input.Transaction)
+		case operationSimulate:
+			account, errs = transactionService.SimulateTransaction(input.Simulation)
+		}
+		if err := internalError(errs); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to apply operation", err)
+			closeRepository()
+			os.Exit(1)
+		}
+		fmt.Println(formatOutput(withTransactionID(newOutput(account, errs), input)))
+	}
+}
+
+// openRepository returns the file repository when a data dir is given, read-only when told so, otherwise the memory
+// repository. Either one keeps indexed only the transactions within the retention, the widest rule window, the
+// idempotency keys within the key retention and the history within the history retention, and records events at the
+// time told by the clock.
+func openRepository(
+	readOnly bool,
+	retention time.Duration,
+	keyRetention time.Duration,
+	historyRetention time.Duration,
+	clock service.Clock,
+) (Repository, func() error, error) {
+	if dataDir == "" {
+		memoryRepository := repository.NewMemoryRepositoryWithRetention(
+			retention,
+			keyRetention,
+			historyRetention,
+			clock,
+		)
+		return &memoryRepository, func() error { return nil }, nil
+	}
+
+	if readOnly {
+		fileRepository, err := repository.OpenFileRepositoryReadOnly(dataDir, retention, keyRetention, historyRetention, clock)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &fileRepository, fileRepository.Close, nil
+	}
+
+	fileRepository, err := repository.NewFileRepository(
+		dataDir,
+		snapshotEvery,
+		retention,
+		keyRetention,
+		historyRetention,
+		clock,
+	)
+	if err != nil {
+		return nil, nil, err
 	}
+	return &fileRepository, fileRepository.Close, nil
 }
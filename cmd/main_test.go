@@ -17,6 +17,17 @@ func Example_main_when_create_account() {
 	// {"account":{"active-card":false,"available-limit":750},"violations":[]}
 }
 
+func Example_main_when_create_account_with_zero_values() {
+	setup("../test/zero_values_account")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"active-card":false,"available-limit":0},"violations":[]}
+	// {"account":{"active-card":false,"available-limit":0},"violations":["card-not-active"]}
+}
+
 func Example_main_when_account_not_initialized() {
 	setup("../test/account_not_initialized")
 	defer teardown()
@@ -55,6 +66,276 @@ func Example_main_when_has_multiple_violations() {
 	// {"account":{"active-card":true,"available-limit":50},"violations":[]}
 }
 
+func Example_main_when_has_multiple_accounts() {
+	setup("../test/multiple_accounts")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"2","active-card":true,"available-limit":50},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"2","active-card":true,"available-limit":30},"violations":[]}
+	// {"account":{},"violations":["account-not-initialized"]}
+	// {"account":{},"violations":["account-already-initialized"]}
+}
+
+func Example_main_when_has_reversals() {
+	setup("../test/reversals")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["transaction-already-reversed"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["transaction-not-found"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["duplicate-transaction-id"]}
+}
+
+func Example_main_when_card_changes() {
+	setup("../test/card_lifecycle")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":false,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"block-reason":"suspected fraud"},"violations":[]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"block-reason":"suspected fraud"},"violations":["card-blocked"]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"block-reason":"suspected fraud"},"violations":["card-transition-not-allowed"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"closed":true},"violations":[]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"closed":true},"violations":["account-closed"]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"closed":true},"violations":["account-closed"]}
+}
+
+func Example_main_when_account_limit_changes() {
+	setup("../test/limit_changes")
+	totalLimit = true
+	defer func() {
+		totalLimit = false
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100,"total-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":40,"total-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":190,"total-limit":250},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":190,"total-limit":250},"violations":["limit-below-used"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":0,"total-limit":60},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":0,"total-limit":60},"violations":["insufficient-limit"]}
+	// {"account":{},"violations":["account-not-initialized"]}
+}
+
+func Example_main_when_simulates_transactions() {
+	setup("../test/simulations")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["insufficient-limit"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["double-transaction"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":50},"violations":[]}
+	// {"account":{},"violations":["account-not-initialized"]}
+}
+
+func Example_main_when_retries_transactions_with_idempotency_keys() {
+	setup("../test/idempotency")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["double-transaction"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":50},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["double-transaction"]}
+}
+
+func Example_main_when_has_holds() {
+	setup("../test/holds")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":40},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":40},"violations":["insufficient-limit"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":55},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":55},"violations":["hold-already-settled"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":5},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":55},"violations":["hold-expired"]}
+}
+
+func Example_main_when_violation_details_are_enabled() {
+	setup("../test/violation_details")
+	violationDetails = true
+	defer func() {
+		violationDetails = false
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":80},"violations":["insufficient-limit"],"violation-details":[{"code":"insufficient-limit","message":"amount exceeds the available limit","metadata":{"available":80,"requested":90}}]}
+	// {"account":{},"violations":["account-already-initialized"]}
+}
+
+func Example_main_when_has_malformed_lines() {
+	setup("../test/malformed_lines")
+	defer teardown()
+
+	main()
+
+	// Output:
+	// {"account":{"active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{},"violations":["invalid-input"],"line":2,"error":"unexpected end of JSON input"}
+	// {"account":{},"violations":["unknown-operation"],"line":3}
+	// {"account":{"active-card":true,"available-limit":10},"violations":[]}
+}
+
+func Example_main_when_line_exceeds_max_line_size() {
+	setup("../test/multiple_accounts")
+	maxLineSize = 100
+	defer func() {
+		maxLineSize = defaultMaxLineSize
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"2","active-card":true,"available-limit":50},"violations":[]}
+	// {"account":{},"violations":["invalid-input"],"line":3,"error":"line too long: exceeds the max line size of 100 bytes"}
+	// {"account":{},"violations":["invalid-input"],"line":4,"error":"line too long: exceeds the max line size of 100 bytes"}
+	// {"account":{},"violations":["invalid-input"],"line":5,"error":"line too long: exceeds the max line size of 100 bytes"}
+	// {"account":{},"violations":["account-already-initialized"]}
+}
+
+func Example_main_when_config_is_given() {
+	setup("../test/multiple_violations")
+	configPath = "../test/config.json"
+	defer func() {
+		configPath = ""
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":90},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":70},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":70},"violations":["double-transaction"]}
+	// {"account":{"active-card":true,"available-limit":70},"violations":["double-transaction"]}
+	// {"account":{"active-card":true,"available-limit":70},"violations":["insufficient-limit","double-transaction"]}
+	// {"account":{"active-card":true,"available-limit":70},"violations":["insufficient-limit","double-transaction"]}
+	// {"account":{"active-card":true,"available-limit":55},"violations":[]}
+}
+
+func Example_main_when_has_spending_limits() {
+	setup("../test/spending_limits")
+	configPath = "../test/spending_limits_config.json"
+	defer func() {
+		configPath = ""
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"active-card":true,"available-limit":2000},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":2000},"violations":["merchant-limit-exceeded"]}
+	// {"account":{"active-card":true,"available-limit":1850},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":1850},"violations":["mcc-limit-exceeded"]}
+	// {"account":{"active-card":true,"available-limit":1650},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":1450},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":1200},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":1200},"violations":["mcc-limit-exceeded"]}
+	// {"account":{"active-card":true,"available-limit":1200},"violations":[]}
+	// {"account":{"active-card":true,"available-limit":1200},"violations":["mcc-limit-exceeded"]}
+}
+
+func Example_main_when_has_spend_limits() {
+	setup("../test/spend_limits")
+	configPath = "../test/spend_limits_config.json"
+	defer func() {
+		configPath = ""
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":1000,"daily-limit":100,"monthly-limit":250},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":940,"daily-limit":100,"monthly-limit":250},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":940,"daily-limit":100,"monthly-limit":250},"violations":["daily-limit-exceeded"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":890,"daily-limit":100,"monthly-limit":250},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":800,"daily-limit":100,"monthly-limit":250},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":800,"daily-limit":100,"monthly-limit":250},"violations":["monthly-limit-exceeded"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":800,"daily-limit":100,"monthly-limit":400},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":740,"daily-limit":100,"monthly-limit":400},"violations":[]}
+}
+
+func Example_main_when_has_too_many_declines() {
+	setup("../test/too_many_declines")
+	configPath = "../test/too_many_declines_config.json"
+	defer func() {
+		configPath = ""
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["insufficient-limit"]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["insufficient-limit"]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"block-reason":"too-many-declines"},"violations":["high-frequency-small-interval","too-many-declines"]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"block-reason":"too-many-declines"},"violations":["card-blocked"]}
+	// {"account":{"account-id":"1","active-card":false,"available-limit":80,"block-reason":"too-many-declines"},"violations":["card-blocked"]}
+}
+
+func Example_main_when_has_currencies() {
+	setup("../test/currencies")
+	ratesPath = "../test/rates.json"
+	defer func() {
+		ratesPath = ""
+		teardown()
+	}()
+
+	main()
+
+	// Output:
+	// {"account":{"account-id":"1","active-card":true,"available-limit":100000,"currency":"BRL"},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":98000,"currency":"BRL"},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":87505,"currency":"BRL"},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":77505,"currency":"BRL"},"violations":[]}
+	// {"account":{"account-id":"1","active-card":true,"available-limit":77505,"currency":"BRL"},"violations":["currency-not-supported"]}
+	// {"account":{"account-id":"2","active-card":true,"available-limit":1000},"violations":[]}
+	// {"account":{"account-id":"2","active-card":true,"available-limit":1000},"violations":["currency-not-supported"]}
+}
+
 func setup(path string) {
 	testFile, _ = os.Open(path)
 	os.Stdin = testFile
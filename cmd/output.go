@@ -2,38 +2,136 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/unknown/authorizer/internal/core/domain"
 )
 
 type Output struct {
-	Account    domain.Account
-	Violations []string
+	Account          domain.Account
+	Violations       []string
+	ViolationDetails []domain.Violation
+	Transactions     []domain.Transaction
+	Decisions        []domain.Decision
+	Events           []domain.Event
+	TransactionID    string
+	Line             int
+	Error            string
+	// hasAccount renders a zero Account, like an account created with zero values, instead of an empty one.
+	hasAccount bool
 }
 
 func (o Output) MarshalJSON() ([]byte, error) {
 	type outputWithAccount struct {
-		Account    domain.Account `json:"account"`
-		Violations []string       `json:"violations"`
+		Account          domain.Account        `json:"account"`
+		Violations       []string              `json:"violations"`
+		ViolationDetails []domain.Violation    `json:"violation-details,omitempty"`
+		Transactions     *[]domain.Transaction `json:"transactions,omitempty"`
+		Decisions        *[]domain.Decision    `json:"decisions,omitempty"`
+		Events           []domain.Event        `json:"events,omitempty"`
+		TransactionID    string                `json:"transaction-id,omitempty"`
+		Line             int                   `json:"line,omitempty"`
+		Error            string                `json:"error,omitempty"`
 	}
 	type outputWithEmptyAccount struct {
-		Account    struct{} `json:"account"`
-		Violations []string `json:"violations"`
+		Account          struct{}              `json:"account"`
+		Violations       []string              `json:"violations"`
+		ViolationDetails []domain.Violation    `json:"violation-details,omitempty"`
+		Transactions     *[]domain.Transaction `json:"transactions,omitempty"`
+		Decisions        *[]domain.Decision    `json:"decisions,omitempty"`
+		Events           []domain.Event        `json:"events,omitempty"`
+		TransactionID    string                `json:"transaction-id,omitempty"`
+		Line             int                   `json:"line,omitempty"`
+		Error            string                `json:"error,omitempty"`
+	}
+
+	var transactions *[]domain.Transaction
+	if o.Transactions != nil {
+		transactions = &o.Transactions
+	}
+	var decisions *[]domain.Decision
+	if o.Decisions != nil {
+		decisions = &o.Decisions
 	}
 
 	emptyAccount := domain.Account{}
-	if o.Account == emptyAccount {
-		return json.Marshal(&outputWithEmptyAccount{Violations: o.Violations})
+	if o.Account == emptyAccount && !o.hasAccount {
+		return json.Marshal(&outputWithEmptyAccount{
+			Violations:       o.Violations,
+			ViolationDetails: o.ViolationDetails,
+			Transactions:     transactions,
+			Decisions:        decisions,
+			Events:           o.Events,
+			TransactionID:    o.TransactionID,
+			Line:             o.Line,
+			Error:            o.Error,
+		})
 	}
-	return json.Marshal(&outputWithAccount{Account: o.Account, Violations: o.Violations})
+	return json.Marshal(&outputWithAccount{
+		Account:          o.Account,
+		Violations:       o.Violations,
+		ViolationDetails: o.ViolationDetails,
+		Transactions:     transactions,
+		Decisions:        decisions,
+		Events:           o.Events,
+		TransactionID:    o.TransactionID,
+		Line:             o.Line,
+		Error:            o.Error,
+	})
 }
 
-func parseOutput(account domain.Account, errs []error) string {
-	violations := getViolationsWith(errs)
+// newOutput returns the output of an operation over the account, detailing its violations when violation details are
+// enabled and with the account total limit only when it is enabled.
+func newOutput(account domain.Account, errs []error) Output {
 	output := Output{
 		Account:    account,
-		Violations: violations,
+		Violations: getViolationsWith(errs),
+		hasAccount: true,
+	}
+	for _, err := range errs {
+		if errors.Is(err, domain.ErrAccountNotInitialized) || errors.Is(err, domain.ErrAccountAlreadyInitialized) {
+			output.hasAccount = false
+		}
+	}
+	if violationDetails {
+		output.ViolationDetails = getViolationDetailsWith(errs)
+	}
+	if !totalLimit {
+		output.Account.TotalLimit = 0
+	}
+	return output
+}
+
+// withTransactionID returns the output of the transaction operation with the id of its transaction when transaction
+// ids are enabled.
+func withTransactionID(output Output, input Input) Output {
+	if transactionID && input.Operation == operationTransaction {
+		output.TransactionID = input.Transaction.ID
+	}
+	return output
+}
+
+func parseOutput(account domain.Account, errs []error) string {
+	return formatOutput(newOutput(account, errs))
+}
+
+func formatOutput(output Output) string {
+	data, err := json.Marshal(output)
+	if err != nil {
+		fmt.Println("failed to marshal output: ", err)
+		return ""
+	}
+
+	return string(data)
+}
+
+// parseInputError returns the output of the input line that could not be parsed, with the parse error unless the line
+// has no known operation.
+func parseInputError(line int, err error) string {
+	output := Output{Violations: []string{errInvalidInput.Error()}, Line: line, Error: err.Error()}
+	if errors.Is(err, errUnknownOperation) {
+		output = Output{Violations: []string{errUnknownOperation.Error()}, Line: line}
 	}
 
 	data, err := json.Marshal(output)
@@ -45,6 +143,16 @@ func parseOutput(account domain.Account, errs []error) string {
 	return string(data)
 }
 
+// internalError returns the first of the errors that is a failure of the operation rather than a violation, if any.
+func internalError(errs []error) error {
+	for _, err := range errs {
+		if errors.Is(err, domain.ErrInternal) {
+			return err
+		}
+	}
+	return nil
+}
+
 func getViolationsWith(errs []error) []string {
 	violations := []string{}
 	if len(errs) > 0 {
@@ -58,3 +166,23 @@ func getViolationsWith(errs []error) []string {
 	}
 	return violations
 }
+
+// getViolationDetailsWith returns the details of every violation, in the order of the violations, or none when no
+// violation has a message or metadata to add to its code.
+func getViolationDetailsWith(errs []error) []domain.Violation {
+	details := []domain.Violation{}
+	detailed := false
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		violation := domain.ViolationOf(err)
+		details = append(details, violation)
+		detailed = detailed || violation.Message != "" || len(violation.Metadata) > 0
+	}
+	if !detailed {
+		return nil
+	}
+	return details
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+	"github.com/unknown/authorizer/internal/core/service"
+)
+
+// loadRates reads the exchange rates file at path, keyed by source and target currency codes like
+// {"USD": {"BRL": 5.25}}. An empty path returns no rates, so only transactions in the account currency are supported.
+func loadRates(path string) (service.ExchangeRates, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rates := service.ExchangeRates{}
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, err
+	}
+	for from, targets := range rates {
+		for to, rate := range targets {
+			if !domain.ValidCurrency(from) || !domain.ValidCurrency(to) {
+				return nil, fmt.Errorf("invalid currency pair %s/%s", from, to)
+			}
+			if rate <= 0 {
+				return nil, fmt.Errorf("rate of %s/%s must be positive", from, to)
+			}
+		}
+	}
+	return rates, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/service"
+)
+
+func Test_loadRates(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should return no rates when path is empty": func(t *testing.T) {
+			// 	when
+			rates, err := loadRates("")
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Nil(t, rates)
+		},
+		"should read rates from given file": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"USD": {"BRL": 5.25, "EUR": 0.92}}`)
+
+			// 	when
+			rates, err := loadRates(givenPath)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, service.ExchangeRates{"USD": {"BRL": 5.25, "EUR": 0.92}}, rates)
+		},
+		"should return error when currency code is invalid": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"usd": {"BRL": 5.25}}`)
+
+			// 	when
+			_, err := loadRates(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "invalid currency pair usd/BRL")
+		},
+		"should return error when currency code is not in ISO 4217": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"USD": {"ABC": 5.25}}`)
+
+			// 	when
+			_, err := loadRates(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "invalid currency pair USD/ABC")
+		},
+		"should return error when rate is not positive": func(t *testing.T) {
+			// 	given
+			givenPath := writeConfig(t, `{"USD": {"BRL": 0}}`)
+
+			// 	when
+			_, err := loadRates(givenPath)
+
+			// 	then
+			assert.EqualError(t, err, "rate of USD/BRL must be positive")
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const defaultMaxLineSize = 1024 * 1024
+
+var errLineTooLong = errors.New("line too long")
+
+// lineReader streams the input line by line, never holding more than maxLineSize bytes of a line in memory.
+type lineReader struct {
+	reader      *bufio.Reader
+	maxLineSize int
+	line        int
+}
+
+func newLineReader(reader io.Reader, maxLineSize int) *lineReader {
+	return &lineReader{
+		reader:      bufio.NewReader(reader),
+		maxLineSize: maxLineSize,
+	}
+}
+
+// Next returns the next line without its line break and its number. A line longer than maxLineSize is skipped with an
+// error wrapping errLineTooLong, so the following lines can still be read. It returns io.EOF after the last line.
+func (l *lineReader) Next() (string, int, error) {
+	var line []byte
+	tooLong := false
+	for {
+		data, err := l.reader.ReadSlice('\n')
+		if len(line)+len(data) > l.maxLineSize+len("\r\n") {
+			tooLong = true
+			line = nil
+		} else if !tooLong {
+			line = append(line, data...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && (len(data) > 0 || len(line) > 0 || tooLong) {
+			break
+		}
+		if err != nil {
+			return "", l.line, err
+		}
+		break
+	}
+
+	l.line++
+	line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+	if tooLong || len(line) > l.maxLineSize {
+		return "", l.line, fmt.Errorf("%w: exceeds the max line size of %d bytes", errLineTooLong, l.maxLineSize)
+	}
+	return string(line), l.line, nil
+}
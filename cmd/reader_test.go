@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_lineReader(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should read lines without line breaks": func(t *testing.T) {
+			// 	given
+			reader := newLineReader(strings.NewReader("first\r\nsecond\nlast"), 16)
+
+			// 	when
+			lines := readLines(t, reader)
+
+			// 	then
+			assert.Equal(t, []string{"first", "second", "last"}, lines)
+		},
+		"should read lines longer than the read buffer": func(t *testing.T) {
+			// 	given
+			longLine := strings.Repeat("a", 10000)
+			reader := newLineReader(strings.NewReader(longLine+"\nlast\n"), 10000)
+
+			// 	when
+			lines := readLines(t, reader)
+
+			// 	then
+			assert.Equal(t, []string{longLine, "last"}, lines)
+		},
+		"should skip line longer than max line size and read the following": func(t *testing.T) {
+			// 	given
+			reader := newLineReader(strings.NewReader("first\n"+strings.Repeat("a", 10000)+"\nlast"), 16)
+
+			// 	when
+			first, _, firstErr := reader.Next()
+			_, line, tooLongErr := reader.Next()
+			last, _, lastErr := reader.Next()
+			_, _, eofErr := reader.Next()
+
+			// 	then
+			assert.NoError(t, firstErr)
+			assert.Equal(t, "first", first)
+			assert.Equal(t, 2, line)
+			assert.True(t, errors.Is(tooLongErr, errLineTooLong))
+			assert.EqualError(t, tooLongErr, "line too long: exceeds the max line size of 16 bytes")
+			assert.NoError(t, lastErr)
+			assert.Equal(t, "last", last)
+			assert.Equal(t, io.EOF, eofErr)
+		},
+		"should return read error": func(t *testing.T) {
+			// 	given
+			reader := newLineReader(iotest.ErrReader(errors.New("broken pipe")), 16)
+
+			// 	when
+			_, _, err := reader.Next()
+
+			// 	then
+			assert.EqualError(t, err, "broken pipe")
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func readLines(t *testing.T, reader *lineReader) []string {
+	lines := []string{}
+	for {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			return lines
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+	"github.com/unknown/authorizer/internal/core/service"
+)
+
+var errInvalidRequest = errors.New("invalid-request")
+
+type server struct {
+	accountService     service.AccountService
+	transactionService service.TransactionService
+	holdService        service.HoldService
+}
+
+func newServer(
+	accountService service.AccountService,
+	transactionService service.TransactionService,
+	holdService service.HoldService,
+) http.Handler {
+	s := server{
+		accountService:     accountService,
+		transactionService: transactionService,
+		holdService:        holdService,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts", handleOperation(operationAccount, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		account, err := accountService.CreateAccount(input.Account)
+		return account, []error{err}
+	}))
+	mux.HandleFunc("/accounts/", s.handleAccount)
+	mux.HandleFunc("/cards", handleOperation(operationCard, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return accountService.ChangeCard(input.Card)
+	}))
+	mux.HandleFunc("/limits", handleOperation(operationLimitChange, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return accountService.ChangeLimit(input.LimitChange)
+	}))
+	mux.HandleFunc("/transactions", handleOperation(operationTransaction, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return transactionService.AuthorizeTransaction(input.Transaction)
+	}))
+	// simulations answer whether the transaction would be authorized and the account as it would be, recording nothing
+	mux.HandleFunc("/simulations", handleOperation(operationSimulate, http.StatusOK, func(input Input) (domain.Account, []error) {
+		return transactionService.SimulateTransaction(input.Simulation)
+	}))
+	mux.HandleFunc("/reversals", handleOperation(operationReversal, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return transactionService.ReverseTransaction(input.Reversal)
+	}))
+	mux.HandleFunc("/holds", handleOperation(operationHold, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return holdService.PlaceHold(input.Hold)
+	}))
+	mux.HandleFunc("/captures", handleOperation(operationCapture, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return holdService.CaptureHold(input.Capture)
+	}))
+	mux.HandleFunc("/releases", handleOperation(operationRelease, http.StatusCreated, func(input Input) (domain.Account, []error) {
+		return holdService.ReleaseHold(input.Release)
+	}))
+	return mux
+}
+
+// handleOperation serves POST requests whose body is the given operation, answering with the account and violations
+// apply returns for it, and successStatus when there are none.
+func handleOperation(operation string, successStatus int, apply func(Input) (domain.Account, []error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		input, err := decodeInput(w, r, operation)
+		if err != nil {
+			writeOutput(w, http.StatusBadRequest, Output{Violations: []string{errInvalidRequest.Error()}})
+			return
+		}
+
+		if input, err = identify(input); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to identify transaction", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		account, errs := apply(input)
+
+		writeResult(w, successStatus, withTransactionID(newOutput(account, errs), input), errs)
+	}
+}
+
+// handleAccount serves GET /accounts/{id}, optionally as it was at the RFC 3339 time given by the at query parameter,
+// GET /accounts/{id}/transactions, GET /accounts/{id}/decisions and GET /accounts/{id}/events.
+func (s server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/")
+	if len(path) > 2 || (len(path) == 2 && path[1] != "transactions" && path[1] != "decisions" && path[1] != "events") {
+		http.NotFound(w, r)
+		return
+	}
+	accountID := path[0]
+
+	if at := r.URL.Query().Get("at"); at != "" && len(path) == 1 {
+		s.handleAccountAt(w, accountID, at)
+		return
+	}
+	if len(path) == 2 && path[1] == "decisions" {
+		s.handleDecisions(w, r, accountID)
+		return
+	}
+
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		writeResult(w, http.StatusOK, newOutput(domain.Account{}, []error{err}), []error{err})
+		return
+	}
+
+	output := newOutput(account, nil)
+	if len(path) == 2 && path[1] == "transactions" {
+		output.Transactions = s.transactionService.GetTransactions(accountID)
+	}
+	if len(path) == 2 && path[1] == "events" {
+		output.Events, _ = s.accountService.GetEvents(accountID)
+	}
+	writeOutput(w, http.StatusOK, output)
+}
+
+func (s server) handleAccountAt(w http.ResponseWriter, accountID string, at string) {
+	atTime, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		writeOutput(w, http.StatusBadRequest, Output{Violations: []string{errInvalidRequest.Error()}})
+		return
+	}
+
+	account, err := s.accountService.GetAccountAt(accountID, atTime)
+	if err != nil {
+		writeResult(w, http.StatusOK, newOutput(domain.Account{}, []error{err}), []error{err})
+		return
+	}
+	writeOutput(w, http.StatusOK, newOutput(account, nil))
+}
+
+// handleDecisions serves GET /accounts/{id}/decisions, filtered by the outcome, violation, from and to query
+// parameters, the times being RFC 3339.
+func (s server) handleDecisions(w http.ResponseWriter, r *http.Request, accountID string) {
+	query := r.URL.Query()
+	filter, err := parseDecisionFilter(query.Get("outcome"), query.Get("violation"), query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeOutput(w, http.StatusBadRequest, Output{Violations: []string{errInvalidRequest.Error()}})
+		return
+	}
+
+	account, err := s.accountService.GetAccount(accountID)
+	if err != nil {
+		writeResult(w, http.StatusOK, newOutput(domain.Account{}, []error{err}), []error{err})
+		return
+	}
+
+	decisions, err := s.transactionService.GetDecisions(accountID, filter)
+	if err != nil {
+		writeResult(w, http.StatusOK, newOutput(domain.Account{}, []error{err}), []error{err})
+		return
+	}
+	output := newOutput(account, nil)
+	output.Decisions = decisions
+	writeOutput(w, http.StatusOK, output)
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// decodeInput decodes the request body, which must be the given operation and, like an input line, at most
+// maxLineSize bytes.
+func decodeInput(w http.ResponseWriter, r *http.Request, operation string) (Input, error) {
+	input := Input{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(maxLineSize))).Decode(&input); err != nil {
+		return Input{}, err
+	}
+	if input.Operation != operation {
+		return Input{}, errUnknownOperation
+	}
+	return input, nil
+}
+
+// writeResult answers with the output of an operation and the status of its violations, or with an internal server
+// error, logged to stderr, when the operation failed.
+func writeResult(w http.ResponseWriter, successStatus int, output Output, errs []error) {
+	if err := internalError(errs); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to serve request", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeOutput(w, statusFor(errs, successStatus), output)
+}
+
+// statusFor maps the violations of an operation to its HTTP status, successStatus when there are none.
+func statusFor(errs []error, successStatus int) int {
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, domain.ErrAccountNotInitialized), errors.Is(err, domain.ErrTransactionNotFound),
+			errors.Is(err, domain.ErrHoldNotFound):
+			return http.StatusNotFound
+		case errors.Is(err, domain.ErrAccountAlreadyInitialized), errors.Is(err, domain.ErrTransactionAlreadyReversed),
+			errors.Is(err, domain.ErrHoldExpired), errors.Is(err, domain.ErrHoldAlreadySettled),
+			errors.Is(err, domain.ErrCardTransitionNotAllowed), errors.Is(err, domain.ErrHoldAlreadyPlaced),
+			errors.Is(err, domain.ErrDuplicateTransactionID), errors.Is(err, domain.ErrIdempotencyKeyReused):
+			return http.StatusConflict
+		default:
+			return http.StatusUnprocessableEntity
+		}
+	}
+	return successStatus
+}
+
+func writeOutput(w http.ResponseWriter, status int, output Output) {
+	data, err := json.Marshal(output)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
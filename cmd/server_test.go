@@ -0,0 +1,502 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+	"github.com/unknown/authorizer/internal/core/service"
+	"github.com/unknown/authorizer/internal/repository"
+)
+
+func Test_server(t *testing.T) {
+	testCases := map[string]func(*testing.T, http.Handler){
+		"should create account": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}`, response.Body.String())
+		},
+		"should return conflict when account already initialized": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["account-already-initialized"]}`, response.Body.String())
+		},
+		"should return bad request when body is invalid": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodPost, "/accounts", `{"account": `)
+
+			// 	then
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["invalid-request"]}`, response.Body.String())
+		},
+		"should return bad request when body exceeds max line size": func(t *testing.T, handler http.Handler) {
+			// 	given
+			maxLineSize = 64
+			defer func() {
+				maxLineSize = defaultMaxLineSize
+			}()
+
+			// 	when
+			response := request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["invalid-request"]}`, response.Body.String())
+		},
+		"should return bad request when body has no known operation": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"refund": {"amount": 20}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["invalid-request"]}`, response.Body.String())
+		},
+		"should return bad request when body is another operation": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["invalid-request"]}`, response.Body.String())
+		},
+		"should get account": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1", "")
+
+			// 	then
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}`, response.Body.String())
+		},
+		"should return not found when account not initialized": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1", "")
+
+			// 	then
+			assert.Equal(t, http.StatusNotFound, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["account-not-initialized"]}`, response.Body.String())
+		},
+		"should authorize transaction": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}`, response.Body.String())
+		},
+		"should reverse transaction": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"id": "t1", "account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/reversals", `{"reversal": {"account-id": "1", "transaction-id": "t1", "time": "2019-02-13T10:05:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}`, response.Body.String())
+		},
+		"should return not found when reversed transaction not found": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/reversals", `{"reversal": {"account-id": "1", "transaction-id": "t1", "time": "2019-02-13T10:05:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusNotFound, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["transaction-not-found"]}`, response.Body.String())
+		},
+		"should return conflict when transaction already reversed": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"id": "t1", "account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+			request(handler, http.MethodPost, "/reversals", `{"reversal": {"account-id": "1", "transaction-id": "t1", "time": "2019-02-13T10:05:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/reversals", `{"reversal": {"account-id": "1", "transaction-id": "t1", "time": "2019-02-13T10:06:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["transaction-already-reversed"]}`, response.Body.String())
+		},
+		"should place and capture hold": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			holdResponse := request(handler, http.MethodPost, "/holds", `{"hold": {"id": "h1", "account-id": "1", "merchant": "hotel", "amount": 60, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/captures", `{"capture": {"account-id": "1", "hold-id": "h1", "amount": 45, "time": "2019-02-13T10:30:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, holdResponse.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":40},"violations":[]}`, holdResponse.Body.String())
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":55},"violations":[]}`, response.Body.String())
+		},
+		"should return conflict when hold already placed": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/holds", `{"hold": {"id": "h1", "account-id": "1", "merchant": "hotel", "amount": 60, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/holds", `{"hold": {"id": "h1", "account-id": "1", "merchant": "hotel", "amount": 10, "time": "2019-02-13T10:05:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":40},"violations":["hold-already-placed"]}`, response.Body.String())
+		},
+		"should return conflict when released hold expired": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/holds", `{"hold": {"id": "h1", "account-id": "1", "merchant": "hotel", "amount": 60, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/releases", `{"release": {"account-id": "1", "hold-id": "h1", "time": "2019-02-13T11:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["hold-expired"]}`, response.Body.String())
+		},
+		"should detail violations when violation details are enabled": func(t *testing.T, handler http.Handler) {
+			// 	given
+			violationDetails = true
+			defer func() {
+				violationDetails = false
+			}()
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"id": "t1", "account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:01:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+			assert.JSONEq(t, `{
+				"account": {"account-id": "1", "active-card": true, "available-limit": 80},
+				"violations": ["double-transaction"],
+				"violation-details": [{
+					"code": "double-transaction",
+					"message": "matches a transaction within 2m0s",
+					"metadata": {
+						"match": ["amount", "merchant"],
+						"transaction": {"id": "t1", "account-id": "1", "amount": 20, "merchant": "Burger King", "time": "2019-02-13T10:00:00Z"},
+						"window": "2m0s"
+					}
+				}]
+			}`, response.Body.String())
+		},
+		"should block card": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/cards", `{"card": {"account-id": "1", "action": "block", "reason": "lost", "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":false,"available-limit":100,"block-reason":"lost"},"violations":[]}`, response.Body.String())
+		},
+		"should return conflict when card transition is not allowed": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/cards", `{"card": {"account-id": "1", "action": "unblock", "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["card-transition-not-allowed"]}`, response.Body.String())
+		},
+		"should change account limit": func(t *testing.T, handler http.Handler) {
+			// 	given
+			totalLimit = true
+			defer func() {
+				totalLimit = false
+			}()
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/limits", `{"account-limit-change": {"account-id": "1", "total-limit": 300, "time": "2019-02-13T10:01:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":280,"total-limit":300},"violations":[]}`, response.Body.String())
+		},
+		"should return unprocessable entity when limit is below used limit": func(t *testing.T, handler http.Handler) {
+			// 	given
+			totalLimit = true
+			defer func() {
+				totalLimit = false
+			}()
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/limits", `{"account-limit-change": {"account-id": "1", "total-limit": 10, "time": "2019-02-13T10:01:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":80,"total-limit":100},"violations":["limit-below-used"]}`, response.Body.String())
+		},
+		"should return unprocessable entity when transaction has violations": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 10}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":10},"violations":["insufficient-limit"]}`, response.Body.String())
+		},
+		"should return id of transaction when enabled": func(t *testing.T, handler http.Handler) {
+			// 	given
+			transactionID = true
+			defer func() {
+				transactionID = false
+			}()
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z", "idempotency-key": "k1"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[],"transaction-id":"k1"}`, response.Body.String())
+		},
+		"should return conflict when idempotency key is reused with another transaction": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z", "idempotency-key": "k1"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 30, "time": "2019-02-13T10:00:00.000Z", "idempotency-key": "k1"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["idempotency-key-reused"]}`, response.Body.String())
+		},
+		"should return conflict when transaction id is taken": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"id": "t1", "account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/transactions", `{"transaction": {"id": "t1", "account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:05:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusConflict, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":["duplicate-transaction-id"]}`, response.Body.String())
+		},
+		"should list account transactions": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"id": "t1", "account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/transactions", "")
+
+			// 	then
+			wantBody := `{
+				"account": {"account-id":"1","active-card":true,"available-limit":80},
+				"violations": [],
+				"transactions": [{"id":"t1","account-id":"1","amount":20,"merchant":"Burger King","time":"2019-02-13T10:00:00Z"}]
+			}`
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, wantBody, response.Body.String())
+		},
+		"should list empty transactions": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/transactions", "")
+
+			// 	then
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[],"transactions":[]}`, response.Body.String())
+		},
+		"should list account ledger": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 10}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/events", "")
+
+			// 	then
+			output := struct {
+				Events []domain.Event `json:"events"`
+			}{}
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &output))
+			assert.Len(t, output.Events, 2)
+			assert.Equal(t, domain.EventAccountCreated, output.Events[0].Type)
+			assert.Equal(t, domain.EventTransactionRejected, output.Events[1].Type)
+			assert.Equal(t, []string{"insufficient-limit"}, output.Events[1].Violations)
+		},
+		"should list rejected decisions of account": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Habbib's", "amount": 120, "time": "2019-02-13T10:01:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/decisions?outcome=rejected&violation=insufficient-limit", "")
+
+			// 	then
+			output := struct {
+				Decisions []domain.Decision `json:"decisions"`
+			}{}
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &output))
+			assert.Len(t, output.Decisions, 1)
+			assert.Equal(t, domain.OutcomeRejected, output.Decisions[0].Outcome)
+			assert.Equal(t, "Habbib's", output.Decisions[0].Transaction.Merchant)
+			assert.Equal(t, 80, output.Decisions[0].Account.AvailableLimit)
+			assert.Equal(t, []string{"insufficient-limit"}, output.Decisions[0].Violations)
+		},
+		"should return bad request when decision filter is invalid": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/decisions?from=yesterday", "")
+
+			// 	then
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		},
+		"should return not found when listing decisions of account not initialized": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/decisions", "")
+
+			// 	then
+			assert.Equal(t, http.StatusNotFound, response.Code)
+			assert.JSONEq(t, `{"account":{},"violations":["account-not-initialized"]}`, response.Body.String())
+		},
+		"should get account at given time": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+			givenTime := time.Now().UTC().Format(time.RFC3339Nano)
+			request(handler, http.MethodPost, "/transactions", `{"transaction": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1?at="+givenTime, "")
+
+			// 	then
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[]}`, response.Body.String())
+		},
+		"should return bad request when given time is invalid": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1?at=yesterday", "")
+
+			// 	then
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		},
+		"should simulate transaction without authorizing it": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/simulations", `{"simulate": {"account-id": "1", "merchant": "Burger King", "amount": 20, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":80},"violations":[]}`, response.Body.String())
+			account := request(handler, http.MethodGet, "/accounts/1/transactions", "")
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":[],"transactions":[]}`, account.Body.String())
+		},
+		"should return violations of simulated transaction": func(t *testing.T, handler http.Handler) {
+			// 	given
+			request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+			// 	when
+			response := request(handler, http.MethodPost, "/simulations", `{"simulate": {"account-id": "1", "merchant": "Burger King", "amount": 120, "time": "2019-02-13T10:00:00.000Z"}}`)
+
+			// 	then
+			assert.Equal(t, http.StatusUnprocessableEntity, response.Code)
+			assert.JSONEq(t, `{"account":{"account-id":"1","active-card":true,"available-limit":100},"violations":["insufficient-limit"]}`, response.Body.String())
+		},
+		"should return method not allowed": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodGet, "/transactions", "")
+
+			// 	then
+			assert.Equal(t, http.StatusMethodNotAllowed, response.Code)
+			assert.Equal(t, http.MethodPost, response.Header().Get("Allow"))
+		},
+		"should return not found for unknown account resource": func(t *testing.T, handler http.Handler) {
+			// 	when
+			response := request(handler, http.MethodGet, "/accounts/1/unknown", "")
+
+			// 	then
+			assert.Equal(t, http.StatusNotFound, response.Code)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			memoryRepository := repository.NewMemoryRepository()
+			accountService := service.NewAccountService(&memoryRepository)
+			transactionService := service.NewTransactionService(&memoryRepository, service.DefaultRules(), service.SystemClock{}, service.TimeBasisEvent, nil)
+			holdService := service.NewHoldService(&memoryRepository, service.DefaultRules(), service.SystemClock{}, service.TimeBasisEvent, time.Hour, nil)
+
+			run(t, newServer(accountService, transactionService, holdService))
+		})
+	}
+}
+
+func request(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return response
+}
+
+func Test_server_when_repository_fails(t *testing.T) {
+	// 	given
+	repository := failingRepository{err: errors.New("disk full")}
+	accountService := service.NewAccountService(repository)
+	transactionService := service.NewTransactionService(repository, service.DefaultRules(), service.SystemClock{}, service.TimeBasisEvent, nil)
+	holdService := service.NewHoldService(repository, service.DefaultRules(), service.SystemClock{}, service.TimeBasisEvent, time.Hour, nil)
+	handler := newServer(accountService, transactionService, holdService)
+
+	// 	when
+	response := request(handler, http.MethodPost, "/accounts", `{"account": {"account-id": "1", "active-card": true, "available-limit": 100}}`)
+
+	// 	then
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.NotContains(t, response.Body.String(), "disk full")
+}
+
+// failingRepository fails to save any account, its other methods are not meant to be called.
+type failingRepository struct {
+	Repository
+	err error
+}
+
+func (r failingRepository) SaveAccount(domain.Account) (domain.Account, error) {
+	return domain.Account{}, r.err
+}
@@ -1,6 +1,44 @@
 package domain
 
+import "time"
+
+// Account holds its limits in minor units of its base Currency, an ISO 4217 code, if given.
 type Account struct {
-	ActiveCard     bool `json:"active-card"`
-	AvailableLimit int  `json:"available-limit"`
+	ID             string `json:"account-id,omitempty"`
+	ActiveCard     bool   `json:"active-card"`
+	AvailableLimit int    `json:"available-limit"`
+	TotalLimit     int    `json:"total-limit,omitempty"`
+	DailyLimit     int    `json:"daily-limit,omitempty"`
+	MonthlyLimit   int    `json:"monthly-limit,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+	BlockReason    string `json:"block-reason,omitempty"`
+	Closed         bool   `json:"closed,omitempty"`
+}
+
+// LimitChange sets the total credit limit of the account, moving the available limit by the same amount. The daily and
+// monthly spend limits are set too when given, zero lifting them, and kept otherwise.
+type LimitChange struct {
+	AccountID    string    `json:"account-id,omitempty"`
+	TotalLimit   int       `json:"total-limit"`
+	DailyLimit   *int      `json:"daily-limit,omitempty"`
+	MonthlyLimit *int      `json:"monthly-limit,omitempty"`
+	CreatedAt    time.Time `json:"time"`
+}
+
+// UsedLimit returns the part of the total limit in use by transactions and holds.
+func (a Account) UsedLimit() int {
+	return a.TotalLimit - a.AvailableLimit
+}
+
+// CardStatus returns the status of the account card in its lifecycle.
+func (a Account) CardStatus() CardStatus {
+	switch {
+	case a.Closed:
+		return CardClosed
+	case a.BlockReason != "":
+		return CardBlocked
+	case a.ActiveCard:
+		return CardActive
+	}
+	return CardInactive
 }
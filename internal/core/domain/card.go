@@ -0,0 +1,57 @@
+package domain
+
+import "time"
+
+type (
+	CardStatus string
+	CardAction string
+)
+
+const (
+	CardActive   CardStatus = "active"
+	CardInactive CardStatus = "inactive"
+	CardBlocked  CardStatus = "blocked"
+	CardClosed   CardStatus = "closed"
+
+	CardActivate   CardAction = "activate"
+	CardDeactivate CardAction = "deactivate"
+	CardBlock      CardAction = "block"
+	CardUnblock    CardAction = "unblock"
+	CardClose      CardAction = "close"
+)
+
+// cardTransitions is the card lifecycle, the statuses each action can be taken from and the status it leads to. A
+// closed account is final.
+var cardTransitions = map[CardAction]struct {
+	from []CardStatus
+	to   CardStatus
+}{
+	CardActivate:   {from: []CardStatus{CardInactive}, to: CardActive},
+	CardDeactivate: {from: []CardStatus{CardActive}, to: CardInactive},
+	CardBlock:      {from: []CardStatus{CardActive, CardInactive}, to: CardBlocked},
+	CardUnblock:    {from: []CardStatus{CardBlocked}, to: CardActive},
+	CardClose:      {from: []CardStatus{CardActive, CardInactive, CardBlocked}, to: CardClosed},
+}
+
+// CardChange takes Action over the card of the account, Reason tells why the card is blocked or deactivated.
+type CardChange struct {
+	AccountID string     `json:"account-id,omitempty"`
+	Action    CardAction `json:"action"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"time"`
+}
+
+// CardTransition returns the status the action leads the card from the given status to, found tells whether the
+// lifecycle allows it.
+func CardTransition(from CardStatus, action CardAction) (CardStatus, bool) {
+	transition, ok := cardTransitions[action]
+	if !ok {
+		return "", false
+	}
+	for _, status := range transition.from {
+		if status == from {
+			return transition.to, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package domain
+
+import "time"
+
+const (
+	OutcomeAuthorized = "authorized"
+	OutcomeRejected   = "rejected"
+)
+
+type (
+	// Decision is the outcome of a transaction checked against the rules: the transaction as it was checked, the account
+	// right after it and its violations, when rejected. DecidedAt is the time the decision was recorded.
+	Decision struct {
+		Outcome     string      `json:"outcome"`
+		Transaction Transaction `json:"transaction"`
+		Account     Account     `json:"account"`
+		Violations  []string    `json:"violations"`
+		DecidedAt   time.Time   `json:"decided-at"`
+	}
+
+	// DecisionFilter selects decisions by outcome, by violation code and by the time range they were decided in, from
+	// inclusive to exclusive. A zero field selects every decision.
+	DecisionFilter struct {
+		Outcome   string
+		Violation string
+		From      time.Time
+		To        time.Time
+	}
+)
+
+// NewDecision returns the decision recorded by the transaction authorized or rejected event, over the account right
+// after the event.
+func NewDecision(event Event, account Account) Decision {
+	outcome := OutcomeAuthorized
+	if event.Type == EventTransactionRejected {
+		outcome = OutcomeRejected
+	}
+	violations := append([]string{}, event.Violations...)
+	return Decision{
+		Outcome:     outcome,
+		Transaction: *event.Transaction,
+		Account:     account,
+		Violations:  violations,
+		DecidedAt:   event.RecordedAt,
+	}
+}
+
+func (f DecisionFilter) Matches(decision Decision) bool {
+	if f.Outcome != "" && decision.Outcome != f.Outcome {
+		return false
+	}
+	if !f.From.IsZero() && decision.DecidedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !decision.DecidedAt.Before(f.To) {
+		return false
+	}
+	if f.Violation == "" {
+		return true
+	}
+	for _, violation := range decision.Violations {
+		if violation == f.Violation {
+			return true
+		}
+	}
+	return false
+}
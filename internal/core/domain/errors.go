@@ -9,4 +9,68 @@ var (
 	ErrInsufficientLimit          = errors.New("insufficient-limit")
 	ErrHighFrequencySmallInterval = errors.New("high-frequency-small-interval")
 	ErrDoubleTransaction          = errors.New("double-transaction")
+	ErrTransactionNotFound        = errors.New("transaction-not-found")
+	ErrTransactionAlreadyReversed = errors.New("transaction-already-reversed")
+	ErrHoldNotFound               = errors.New("hold-not-found")
+	ErrHoldExpired                = errors.New("hold-expired")
+	ErrHoldAlreadySettled         = errors.New("hold-already-settled")
+	ErrCaptureExceedsHold         = errors.New("capture-exceeds-hold")
+	ErrCaptureAmountInvalid       = errors.New("capture-amount-invalid")
+	ErrCardBlocked                = errors.New("card-blocked")
+	ErrAccountClosed              = errors.New("account-closed")
+	ErrCardTransitionNotAllowed   = errors.New("card-transition-not-allowed")
+	ErrBlockReasonRequired        = errors.New("block-reason-required")
+	ErrLimitBelowUsed             = errors.New("limit-below-used")
+	ErrLimitInvalid               = errors.New("limit-invalid")
+	ErrMerchantLimitExceeded      = errors.New("merchant-limit-exceeded")
+	ErrMCCLimitExceeded           = errors.New("mcc-limit-exceeded")
+	ErrDailyLimitExceeded         = errors.New("daily-limit-exceeded")
+	ErrMonthlyLimitExceeded       = errors.New("monthly-limit-exceeded")
+	ErrTransactionTimeInvalid     = errors.New("transaction-time-invalid")
+	ErrCurrencyNotSupported       = errors.New("currency-not-supported")
+	ErrTooManyDeclines            = errors.New("too-many-declines")
+	ErrHoldAmountInvalid          = errors.New("hold-amount-invalid")
+	ErrHoldIDRequired             = errors.New("hold-id-required")
+	ErrHoldAlreadyPlaced          = errors.New("hold-already-placed")
+	ErrDuplicateTransactionID     = errors.New("duplicate-transaction-id")
+	ErrIdempotencyKeyReused       = errors.New("idempotency-key-reused")
 )
+
+// Errors of the repositories, which are not violations. A repository tells whether it holds an account with
+// ErrAccountNotFound and ErrAccountExists, and whether the id of a hold or transaction is already taken with
+// ErrHoldExists and ErrTransactionExists, and whether an idempotency key was recorded with another transaction with
+// ErrKeyReused, any other error it returns is a failure of its own, wrapped in ErrInternal on its way out of the
+// services.
+var (
+	ErrAccountNotFound   = errors.New("account not found")
+	ErrAccountExists     = errors.New("account already exists")
+	ErrHoldExists        = errors.New("hold already exists")
+	ErrTransactionExists = errors.New("transaction already exists")
+	ErrKeyReused         = errors.New("idempotency key recorded with another transaction")
+	ErrInternal          = errors.New("internal error")
+)
+
+// violationErrors indexes the violation errors by their code.
+var violationErrors = map[string]error{}
+
+func init() {
+	for _, err := range []error{
+		ErrAccountNotInitialized, ErrAccountAlreadyInitialized, ErrCardNotActive, ErrInsufficientLimit,
+		ErrHighFrequencySmallInterval, ErrDoubleTransaction, ErrTransactionNotFound, ErrTransactionAlreadyReversed,
+		ErrHoldNotFound, ErrHoldExpired, ErrHoldAlreadySettled, ErrCaptureExceedsHold, ErrCaptureAmountInvalid,
+		ErrCardBlocked, ErrAccountClosed, ErrCardTransitionNotAllowed, ErrBlockReasonRequired, ErrLimitBelowUsed,
+		ErrMerchantLimitExceeded, ErrMCCLimitExceeded, ErrDailyLimitExceeded, ErrMonthlyLimitExceeded,
+		ErrTransactionTimeInvalid, ErrCurrencyNotSupported, ErrTooManyDeclines, ErrHoldAmountInvalid, ErrHoldIDRequired,
+		ErrHoldAlreadyPlaced, ErrDuplicateTransactionID, ErrLimitInvalid, ErrIdempotencyKeyReused,
+	} {
+		violationErrors[err.Error()] = err
+	}
+}
+
+// errorOf returns the violation error with the code, or a new error carrying the code when there is none.
+func errorOf(code string) error {
+	if err, ok := violationErrors[code]; ok {
+		return err
+	}
+	return errors.New(code)
+}
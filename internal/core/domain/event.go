@@ -0,0 +1,229 @@
+package domain
+
+import "time"
+
+type EventType string
+
+const (
+	EventAccountCreated        EventType = "account-created"
+	EventTransactionAuthorized EventType = "transaction-authorized"
+	EventTransactionRejected   EventType = "transaction-rejected"
+	EventTransactionReversed   EventType = "transaction-reversed"
+	EventHoldPlaced            EventType = "hold-placed"
+	EventHoldCaptured          EventType = "hold-captured"
+	EventHoldReleased          EventType = "hold-released"
+	EventHoldExpired           EventType = "hold-expired"
+	EventCardChanged           EventType = "card-changed"
+	EventTotalLimitChanged     EventType = "total-limit-changed"
+	EventHistoryTrimmed        EventType = "history-trimmed"
+	// EventLimitAdjusted moved the available limit by an Adjustment, it is no longer recorded, total-limit-changed
+	// replaced it, but ledgers holding it are still replayed.
+	EventLimitAdjusted EventType = "limit-adjusted"
+)
+
+// Event is an entry of the append-only account ledger, the account state is derived by folding its events.
+// RecordedAt is the time the event was appended to the ledger, transactions keep their own time.
+type Event struct {
+	Sequence    uint64       `json:"seq"`
+	Type        EventType    `json:"type"`
+	AccountID   string       `json:"account-id,omitempty"`
+	Account     *Account     `json:"account,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Reversal    *Reversal    `json:"reversal,omitempty"`
+	Hold        *Hold        `json:"hold,omitempty"`
+	Capture     *Capture     `json:"capture,omitempty"`
+	Release     *Release     `json:"release,omitempty"`
+	CardChange  *CardChange  `json:"card-change,omitempty"`
+	LimitChange *LimitChange `json:"limit-change,omitempty"`
+	Violations  []string     `json:"violations,omitempty"`
+	// ViolationDetails are the Violations with their messages and metadata.
+	ViolationDetails []Violation `json:"violation-details,omitempty"`
+	Adjustment       int         `json:"adjustment,omitempty"`
+	RecordedAt       time.Time   `json:"recorded-at"`
+}
+
+func NewAccountCreated(account Account, recordedAt time.Time) Event {
+	return Event{
+		Type:       EventAccountCreated,
+		AccountID:  account.ID,
+		Account:    &account,
+		RecordedAt: recordedAt,
+	}
+}
+
+func NewTransactionAuthorized(transaction Transaction, recordedAt time.Time) Event {
+	return Event{
+		Type:        EventTransactionAuthorized,
+		AccountID:   transaction.AccountID,
+		Transaction: &transaction,
+		RecordedAt:  recordedAt,
+	}
+}
+
+func NewTransactionRejected(transaction Transaction, violations []error, recordedAt time.Time) Event {
+	codes := []string{}
+	details := []Violation{}
+	for _, violation := range violations {
+		codes = append(codes, violation.Error())
+		details = append(details, ViolationOf(violation))
+	}
+	return Event{
+		Type:             EventTransactionRejected,
+		AccountID:        transaction.AccountID,
+		Transaction:      &transaction,
+		Violations:       codes,
+		ViolationDetails: details,
+		RecordedAt:       recordedAt,
+	}
+}
+
+// NewTransactionReversed returns the event undoing the given authorized transaction.
+func NewTransactionReversed(transaction Transaction, reversal Reversal, recordedAt time.Time) Event {
+	return Event{
+		Type:        EventTransactionReversed,
+		AccountID:   transaction.AccountID,
+		Transaction: &transaction,
+		Reversal:    &reversal,
+		RecordedAt:  recordedAt,
+	}
+}
+
+func NewHoldPlaced(hold Hold, recordedAt time.Time) Event {
+	hold.Status = HoldHeld
+	return Event{
+		Type:       EventHoldPlaced,
+		AccountID:  hold.AccountID,
+		Hold:       &hold,
+		RecordedAt: recordedAt,
+	}
+}
+
+// NewHoldCaptured returns the event settling the hold, its Transaction is the final debit of the captured amount.
+func NewHoldCaptured(hold Hold, capture Capture, recordedAt time.Time) Event {
+	return Event{
+		Type:      EventHoldCaptured,
+		AccountID: hold.AccountID,
+		Hold:      &hold,
+		Capture:   &capture,
+		Transaction: &Transaction{
+			ID:        hold.ID,
+			AccountID: hold.AccountID,
+			Money:     capture.Money,
+			Original:  capture.Original,
+			Merchant:  hold.Merchant,
+			MCC:       hold.MCC,
+			CreatedAt: capture.CreatedAt,
+		},
+		RecordedAt: recordedAt,
+	}
+}
+
+func NewHoldReleased(hold Hold, release Release, recordedAt time.Time) Event {
+	return Event{
+		Type:       EventHoldReleased,
+		AccountID:  hold.AccountID,
+		Hold:       &hold,
+		Release:    &release,
+		RecordedAt: recordedAt,
+	}
+}
+
+func NewHoldExpired(hold Hold, recordedAt time.Time) Event {
+	return Event{
+		Type:       EventHoldExpired,
+		AccountID:  hold.AccountID,
+		Hold:       &hold,
+		RecordedAt: recordedAt,
+	}
+}
+
+func NewCardChanged(change CardChange, recordedAt time.Time) Event {
+	return Event{
+		Type:       EventCardChanged,
+		AccountID:  change.AccountID,
+		CardChange: &change,
+		RecordedAt: recordedAt,
+	}
+}
+
+func NewTotalLimitChanged(change LimitChange, recordedAt time.Time) Event {
+	return Event{
+		Type:        EventTotalLimitChanged,
+		AccountID:   change.AccountID,
+		LimitChange: &change,
+		RecordedAt:  recordedAt,
+	}
+}
+
+// NewHistoryTrimmed returns the event standing for the events trimmed from the start of a ledger, its Account is the
+// account they folded into.
+func NewHistoryTrimmed(account Account, sequence uint64, recordedAt time.Time) Event {
+	return Event{
+		Sequence:   sequence,
+		Type:       EventHistoryTrimmed,
+		AccountID:  account.ID,
+		Account:    &account,
+		RecordedAt: recordedAt,
+	}
+}
+
+// Apply returns the account resulting of the given event.
+func (a Account) Apply(event Event) Account {
+	switch event.Type {
+	case EventAccountCreated, EventHistoryTrimmed:
+		return *event.Account
+	case EventTransactionAuthorized:
+		a.AvailableLimit -= event.Transaction.Amount
+	case EventTransactionReversed:
+		a.AvailableLimit += event.Transaction.Amount
+	case EventLimitAdjusted:
+		a.AvailableLimit += event.Adjustment
+	case EventHoldPlaced:
+		a.AvailableLimit -= event.Hold.Amount
+	case EventHoldCaptured:
+		a.AvailableLimit += event.Hold.Amount - event.Transaction.Amount
+	case EventHoldReleased, EventHoldExpired:
+		a.AvailableLimit += event.Hold.Amount
+	case EventCardChanged:
+		return a.applyCardChange(*event.CardChange)
+	case EventTotalLimitChanged:
+		a.AvailableLimit += event.LimitChange.TotalLimit - a.TotalLimit
+		a.TotalLimit = event.LimitChange.TotalLimit
+		if event.LimitChange.DailyLimit != nil {
+			a.DailyLimit = *event.LimitChange.DailyLimit
+		}
+		if event.LimitChange.MonthlyLimit != nil {
+			a.MonthlyLimit = *event.LimitChange.MonthlyLimit
+		}
+	}
+	return a
+}
+
+func (a Account) applyCardChange(change CardChange) Account {
+	switch change.Action {
+	case CardActivate:
+		a.ActiveCard = true
+	case CardDeactivate:
+		a.ActiveCard = false
+	case CardBlock:
+		a.ActiveCard = false
+		a.BlockReason = change.Reason
+	case CardUnblock:
+		a.ActiveCard = true
+		a.BlockReason = ""
+	case CardClose:
+		a.ActiveCard = false
+		a.BlockReason = ""
+		a.Closed = true
+	}
+	return a
+}
+
+// Fold returns the account resulting of applying the events in order.
+func Fold(events []Event) Account {
+	account := Account{}
+	for _, event := range events {
+		account = account.Apply(event)
+	}
+	return account
+}
@@ -0,0 +1,63 @@
+package domain
+
+import "time"
+
+type HoldStatus string
+
+const (
+	HoldHeld     HoldStatus = "held"
+	HoldCaptured HoldStatus = "captured"
+	HoldReleased HoldStatus = "released"
+	HoldExpired  HoldStatus = "expired"
+)
+
+// Hold reserves its Money out of the account limit until it is captured, released or expires at ExpiresAt. Like a
+// transaction, its money is in the account currency when it has no currency, and a hold converted to the account
+// currency keeps the money it was placed with as Original.
+type Hold struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account-id,omitempty"`
+	Money
+	Original  *Money     `json:"original,omitempty"`
+	Merchant  string     `json:"merchant"`
+	MCC       string     `json:"mcc,omitempty"`
+	CreatedAt time.Time  `json:"time"`
+	ExpiresAt time.Time  `json:"expires-at"`
+	Status    HoldStatus `json:"status,omitempty"`
+}
+
+// Capture settles its Money out of the hold with HoldID as a final debit, up to the held amount, freeing the rest. Its
+// money is in the account currency when it has no currency, and a capture keeps the money it was captured with as
+// Original when converted.
+type Capture struct {
+	AccountID string `json:"account-id,omitempty"`
+	HoldID    string `json:"hold-id"`
+	Money
+	Original  *Money    `json:"original,omitempty"`
+	CreatedAt time.Time `json:"time"`
+}
+
+// Release frees the whole amount of the hold with HoldID.
+type Release struct {
+	AccountID string    `json:"account-id,omitempty"`
+	HoldID    string    `json:"hold-id"`
+	CreatedAt time.Time `json:"time"`
+}
+
+// Transaction returns the hold as the transaction the authorization rules are evaluated over.
+func (h Hold) Transaction() Transaction {
+	return Transaction{
+		ID:        h.ID,
+		AccountID: h.AccountID,
+		Money:     h.Money,
+		Original:  h.Original,
+		Merchant:  h.Merchant,
+		MCC:       h.MCC,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+// ExpiredAt tells whether the hold is still held past its expiration at the given time.
+func (h Hold) ExpiredAt(at time.Time) bool {
+	return h.Status == HoldHeld && !at.Before(h.ExpiresAt)
+}
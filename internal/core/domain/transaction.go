@@ -2,8 +2,42 @@ package domain
 
 import "time"
 
+// Transaction is a debit of its Money at Merchant, MCC is the ISO 18245 merchant category code of the merchant, if
+// known. Its money is in the account currency when it has no currency. A transaction converted to the account currency
+// keeps the money it was made with as Original. A transaction sent again with the IdempotencyKey of a recorded one is
+// answered with the recorded result.
 type Transaction struct {
-	Amount    int       `json:"amount"`
+	ID             string `json:"id,omitempty"`
+	AccountID      string `json:"account-id,omitempty"`
+	IdempotencyKey string `json:"idempotency-key,omitempty"`
+	Money
+	Original  *Money    `json:"original,omitempty"`
 	Merchant  string    `json:"merchant"`
+	MCC       string    `json:"mcc,omitempty"`
 	CreatedAt time.Time `json:"time"`
+	Reversed  bool      `json:"reversed,omitempty"`
+}
+
+// Authorization is the outcome of authorizing a transaction over its account: the transaction to record, in the
+// currency of the account, and its violations, the transaction being rejected when there are any. CardChange, when
+// there is one, is recorded along with the rejection, in the same commit.
+type Authorization struct {
+	Transaction Transaction
+	Violations  []error
+	CardChange  *CardChange
+}
+
+// Spending sums the amounts an account spent over the time range from, inclusive, to, exclusive. AtMerchant and
+// InCategory narrow it down to the amounts spent at a merchant and at the merchants of a category.
+type Spending interface {
+	SpentBetween(from time.Time, to time.Time) int
+	AtMerchant(merchant string) Spending
+	InCategory(mcc string) Spending
+}
+
+// Reversal undoes the authorized transaction with TransactionID, restoring its amount to the account limit.
+type Reversal struct {
+	AccountID     string    `json:"account-id,omitempty"`
+	TransactionID string    `json:"transaction-id"`
+	CreatedAt     time.Time `json:"time"`
 }
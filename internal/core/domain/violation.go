@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Violation details why an operation was violated. Its code is the one of the violation error it wraps, so
+// errors.Is still matches it against the errors in this package.
+type Violation struct {
+	Code     string                 `json:"code"`
+	Message  string                 `json:"message,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	err      error
+}
+
+func NewViolation(err error, message string, metadata map[string]interface{}) Violation {
+	return Violation{
+		Code:     err.Error(),
+		Message:  message,
+		Metadata: metadata,
+		err:      err,
+	}
+}
+
+// ViolationOf returns err as a Violation, wrapping it without details when it is a bare violation error.
+func ViolationOf(err error) Violation {
+	violation := Violation{}
+	if errors.As(err, &violation) {
+		return violation
+	}
+	return Violation{Code: err.Error(), err: err}
+}
+
+func (v Violation) Error() string {
+	return v.Code
+}
+
+func (v Violation) Unwrap() error {
+	return v.err
+}
+
+// UnmarshalJSON reads the violation back wrapping the violation error of its code, so errors.Is still matches it.
+func (v *Violation) UnmarshalJSON(data []byte) error {
+	type violation Violation
+	decoded := violation{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*v = Violation(decoded)
+	v.err = errorOf(v.Code)
+	return nil
+}
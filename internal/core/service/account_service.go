@@ -1,12 +1,24 @@
 package service
 
-import "github.com/unknown/authorizer/internal/core/domain"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
 
 type (
 	AccountRepository interface {
 		SaveAccount(domain.Account) (domain.Account, error)
-		FindAccount() (domain.Account, error)
-		UpdateAccountLimit(newAvailableLimit int)
+		FindAccount(accountID string) (domain.Account, error)
+		FindEvents(accountID string) []domain.Event
+		// CommitCardChange atomically validates the card change over its account, recording it when there are no
+		// violations.
+		CommitCardChange(change domain.CardChange, validate func(domain.Account) []error) (domain.Account, []error, error)
+		// CommitLimitChange atomically validates the limit change over its account, recording it when there are no
+		// violations.
+		CommitLimitChange(change domain.LimitChange, validate func(domain.Account) []error) (domain.Account, []error, error)
 	}
 
 	AccountService struct {
@@ -18,27 +30,165 @@ func NewAccountService(repository AccountRepository) AccountService {
 	return AccountService{repository: repository}
 }
 
+// CreateAccount saves the account, its total limit is the available one unless given. Its currency, if given, must be
+// an ISO 4217 code. Its limits can not be negative, nor its total limit below the available one.
 func (s AccountService) CreateAccount(account domain.Account) (domain.Account, error) {
-	if account, err := s.repository.SaveAccount(account); err == nil {
-		return account, nil
+	if account.Currency != "" && !domain.ValidCurrency(account.Currency) {
+		return domain.Account{}, domain.ErrCurrencyNotSupported
+	}
+	if account.TotalLimit == 0 {
+		account.TotalLimit = account.AvailableLimit
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"available-limit", account.AvailableLimit},
+		{"total-limit", account.TotalLimit},
+		{"daily-limit", account.DailyLimit},
+		{"monthly-limit", account.MonthlyLimit},
+	} {
+		if err := negativeLimit(limit.name, limit.value); err != nil {
+			return domain.Account{}, err
+		}
+	}
+	if account.TotalLimit < account.AvailableLimit {
+		return domain.Account{}, domain.NewViolation(domain.ErrLimitInvalid, "total limit is below the available limit", map[string]interface{}{
+			"total-limit":     account.TotalLimit,
+			"available-limit": account.AvailableLimit,
+		})
+	}
+	account, err := s.repository.SaveAccount(account)
+	if errors.Is(err, domain.ErrAccountExists) {
+		return domain.Account{}, domain.ErrAccountAlreadyInitialized
+	}
+	if err != nil {
+		return domain.Account{}, repositoryError(err)
 	}
-	return domain.Account{}, domain.ErrAccountAlreadyInitialized
+	return account, nil
 }
 
-func (s AccountService) GetAccount() (domain.Account, error) {
-	if account, err := s.repository.FindAccount(); err == nil {
-		return account, nil
+func (s AccountService) GetAccount(accountID string) (domain.Account, error) {
+	account, err := s.repository.FindAccount(accountID)
+	if err != nil {
+		return domain.Account{}, repositoryError(err)
 	}
-	return domain.Account{}, domain.ErrAccountNotInitialized
+	return account, nil
 }
 
-func (s AccountService) SetAccountLimit(limit int) domain.Account {
-	if limit < 0 {
-		limit = 0
+// ChangeCard takes the card of the account through its lifecycle: an inactive card is activated, an active one
+// deactivated, either one blocked with a reason until unblocked, and any of them closed for good with the account.
+func (s AccountService) ChangeCard(change domain.CardChange) (domain.Account, []error) {
+	account, errs, err := s.repository.CommitCardChange(change, func(account domain.Account) []error {
+		status := account.CardStatus()
+		if status == domain.CardClosed {
+			return []error{domain.ErrAccountClosed}
+		}
+		if change.Action == domain.CardBlock && change.Reason == "" {
+			return []error{domain.ErrBlockReasonRequired}
+		}
+		if _, ok := domain.CardTransition(status, change.Action); !ok {
+			return []error{domain.NewViolation(
+				domain.ErrCardTransitionNotAllowed,
+				fmt.Sprintf("can not %s a card that is %s", change.Action, status),
+				map[string]interface{}{"status": status, "action": change.Action},
+			)}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
 	}
-	s.repository.UpdateAccountLimit(limit)
 
-	account, _ := s.repository.FindAccount()
+	return account, errs
+}
+
+// ChangeLimit raises or lowers the total limit of the account, moving the available limit by the same amount. The new
+// limit can not be below the part already in use. The daily and monthly spend limits are changed along when given, and
+// can not be negative.
+func (s AccountService) ChangeLimit(change domain.LimitChange) (domain.Account, []error) {
+	account, errs, err := s.repository.CommitLimitChange(change, func(account domain.Account) []error {
+		if account.Closed {
+			return []error{domain.ErrAccountClosed}
+		}
+		errs := []error{}
+		if change.DailyLimit != nil {
+			if err := negativeLimit("daily-limit", *change.DailyLimit); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if change.MonthlyLimit != nil {
+			if err := negativeLimit("monthly-limit", *change.MonthlyLimit); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		if change.TotalLimit < account.UsedLimit() || change.TotalLimit < 0 {
+			return []error{domain.NewViolation(domain.ErrLimitBelowUsed, "total limit is below the used limit", map[string]interface{}{
+				"requested": change.TotalLimit,
+				"used":      account.UsedLimit(),
+			})}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
+	}
 
-	return account
+	return account, errs
+}
+
+// GetEvents returns the ledger of the account, the events its state is derived from.
+func (s AccountService) GetEvents(accountID string) ([]domain.Event, error) {
+	events := s.repository.FindEvents(accountID)
+	if len(events) == 0 {
+		return nil, domain.ErrAccountNotInitialized
+	}
+	return events, nil
+}
+
+// GetAccountAt reconstructs the account as it was at the given time, folding the events recorded until then. A time
+// before the history trimmed from the ledger finds no account.
+func (s AccountService) GetAccountAt(accountID string, at time.Time) (domain.Account, error) {
+	events, err := s.GetEvents(accountID)
+	if err != nil {
+		return domain.Account{}, err
+	}
+
+	pastEvents := []domain.Event{}
+	for _, event := range events {
+		if !event.RecordedAt.After(at) {
+			pastEvents = append(pastEvents, event)
+		}
+	}
+	if len(pastEvents) == 0 {
+		return domain.Account{}, domain.ErrAccountNotInitialized
+	}
+	if first := pastEvents[0].Type; first != domain.EventAccountCreated && first != domain.EventHistoryTrimmed {
+		return domain.Account{}, domain.ErrAccountNotInitialized
+	}
+
+	return domain.Fold(pastEvents), nil
+}
+
+// negativeLimit returns the violation of the named limit when it is negative, nil otherwise.
+func negativeLimit(name string, limit int) error {
+	if limit >= 0 {
+		return nil
+	}
+	return domain.NewViolation(domain.ErrLimitInvalid, fmt.Sprintf("%s is negative", name), map[string]interface{}{
+		"limit":     name,
+		"requested": limit,
+	})
+}
+
+// repositoryError returns the violation of an account the repository does not hold, or the failure of the repository
+// wrapped in domain.ErrInternal.
+func repositoryError(err error) error {
+	if errors.Is(err, domain.ErrAccountNotFound) {
+		return domain.ErrAccountNotInitialized
+	}
+	return fmt.Errorf("%w: %v", domain.ErrInternal, err)
 }
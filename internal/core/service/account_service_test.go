@@ -3,14 +3,17 @@ package service
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/unknown/authorizer/internal/core/domain"
 )
 
 func TestCreateAccount(t *testing.T) {
 	givenErr := errors.New("repository error")
 	givenAccount := domain.Account{
+		ID:             "1",
 		ActiveCard:     false,
 		AvailableLimit: 100,
 	}
@@ -18,7 +21,8 @@ func TestCreateAccount(t *testing.T) {
 	testCases := map[string]func(*testing.T, *accountRepositoryMock){
 		"should create account with success": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
 			// 	given
-			accountRepositoryMock.On("SaveAccount", givenAccount).Return(givenAccount, nil)
+			wantAccount := domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100, TotalLimit: 100}
+			accountRepositoryMock.On("SaveAccount", wantAccount).Return(wantAccount, nil)
 
 			accountService := NewAccountService(accountRepositoryMock)
 
@@ -26,12 +30,99 @@ func TestCreateAccount(t *testing.T) {
 			account, err := accountService.CreateAccount(givenAccount)
 
 			// 	then
-			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, wantAccount, account)
+			assert.NoError(t, err)
+		},
+		"should keep given total limit": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenLimitedAccount := domain.Account{ID: "1", AvailableLimit: 100, TotalLimit: 500}
+			accountRepositoryMock.On("SaveAccount", givenLimitedAccount).Return(givenLimitedAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenLimitedAccount)
+
+			// 	then
+			assert.Equal(t, givenLimitedAccount, account)
 			assert.NoError(t, err)
 		},
-		"should return error when repository fails to save": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+		"should return error when currency is not a currency code": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenForeignAccount := domain.Account{ID: "1", AvailableLimit: 100, Currency: "real"}
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenForeignAccount)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.Equal(t, domain.ErrCurrencyNotSupported, err)
+		},
+		"should return error when currency is not in ISO 4217": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenForeignAccount := domain.Account{ID: "1", AvailableLimit: 100, Currency: "XYZ"}
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenForeignAccount)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.Equal(t, domain.ErrCurrencyNotSupported, err)
+		},
+		"should return error when a limit is negative": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenNegativeAccount := domain.Account{ID: "1", AvailableLimit: 100, DailyLimit: -10}
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenNegativeAccount)
+
+			// 	then
+			wantErr := domain.NewViolation(domain.ErrLimitInvalid, "daily-limit is negative", map[string]interface{}{
+				"limit":     "daily-limit",
+				"requested": -10,
+			})
+			assert.Empty(t, account)
+			assert.Equal(t, wantErr, err)
+		},
+		"should return error when available limit is negative": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenNegativeAccount := domain.Account{ID: "1", AvailableLimit: -100}
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenNegativeAccount)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ErrorIs(t, err, domain.ErrLimitInvalid)
+		},
+		"should return error when total limit is below available limit": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
 			// 	given
-			accountRepositoryMock.On("SaveAccount", givenAccount).Return(domain.Account{}, givenErr)
+			givenLimitedAccount := domain.Account{ID: "1", AvailableLimit: 500, TotalLimit: 100}
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenLimitedAccount)
+
+			// 	then
+			wantErr := domain.NewViolation(domain.ErrLimitInvalid, "total limit is below the available limit", map[string]interface{}{
+				"total-limit":     100,
+				"available-limit": 500,
+			})
+			assert.Empty(t, account)
+			assert.Equal(t, wantErr, err)
+		},
+		"should return violation when account already exists": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("SaveAccount", mock.Anything).Return(givenAccount, domain.ErrAccountExists)
 
 			accountService := NewAccountService(accountRepositoryMock)
 
@@ -42,6 +133,20 @@ func TestCreateAccount(t *testing.T) {
 			assert.Empty(t, account)
 			assert.EqualError(t, err, domain.ErrAccountAlreadyInitialized.Error())
 		},
+		"should return internal error when repository fails to save": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("SaveAccount", mock.Anything).Return(domain.Account{}, givenErr)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.CreateAccount(givenAccount)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ErrorIs(t, err, domain.ErrInternal)
+			assert.EqualError(t, err, "internal error: repository error")
+		},
 	}
 
 	for name, run := range testCases {
@@ -58,6 +163,7 @@ func TestCreateAccount(t *testing.T) {
 func TestGetAccount(t *testing.T) {
 	givenErr := errors.New("repository error")
 	givenAccount := domain.Account{
+		ID:             "1",
 		ActiveCard:     false,
 		AvailableLimit: 100,
 	}
@@ -65,30 +171,43 @@ func TestGetAccount(t *testing.T) {
 	testCases := map[string]func(*testing.T, *accountRepositoryMock){
 		"should get account with success": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
 			// 	given
-			accountRepositoryMock.On("FindAccount").Return(givenAccount, nil)
+			accountRepositoryMock.On("FindAccount", "1").Return(givenAccount, nil)
 
 			accountService := NewAccountService(accountRepositoryMock)
 
 			// 	when
-			account, err := accountService.GetAccount()
+			account, err := accountService.GetAccount("1")
 
 			// 	then
 			assert.Equal(t, givenAccount, account)
 			assert.NoError(t, err)
 		},
-		"should return error when repository fails to find": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+		"should return violation when account not found": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
 			// 	given
-			accountRepositoryMock.On("FindAccount").Return(domain.Account{}, givenErr)
+			accountRepositoryMock.On("FindAccount", "1").Return(domain.Account{}, domain.ErrAccountNotFound)
 
 			accountService := NewAccountService(accountRepositoryMock)
 
 			// 	when
-			account, err := accountService.GetAccount()
+			account, err := accountService.GetAccount("1")
 
 			// 	then
 			assert.Empty(t, account)
 			assert.EqualError(t, err, domain.ErrAccountNotInitialized.Error())
 		},
+		"should return internal error when repository fails to find": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("FindAccount", "1").Return(domain.Account{}, givenErr)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.GetAccount("1")
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ErrorIs(t, err, domain.ErrInternal)
+		},
 	}
 
 	for name, run := range testCases {
@@ -102,43 +221,407 @@ func TestGetAccount(t *testing.T) {
 	}
 }
 
-func TestSetAccountLimit(t *testing.T) {
+func TestChangeCard(t *testing.T) {
+	givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}
+
 	testCases := map[string]func(*testing.T, *accountRepositoryMock){
-		"should update limit and return account": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+		"should deactivate active card": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
 			// 	given
-			givenAccount := domain.Account{
-				ActiveCard:     true,
-				AvailableLimit: 100,
-			}
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardDeactivate}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(givenAccount, nil)
 
-			accountRepositoryMock.On("UpdateAccountLimit", 100)
-			accountRepositoryMock.On("FindAccount").Return(givenAccount, nil)
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100}, account)
+			assert.Empty(t, errs)
+		},
+		"should block card with reason": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardBlock, Reason: "lost"}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(givenAccount, nil)
 
 			accountService := NewAccountService(accountRepositoryMock)
 
 			// 	when
-			account := accountService.SetAccountLimit(100)
+			account, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100, BlockReason: "lost"}, account)
+			assert.Equal(t, domain.CardBlocked, account.CardStatus())
+			assert.Empty(t, errs)
+		},
+		"should unblock blocked card as active": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardUnblock}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(domain.Account{ID: "1", AvailableLimit: 100, BlockReason: "lost"}, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeCard(givenChange)
 
 			// 	then
 			assert.Equal(t, givenAccount, account)
+			assert.Empty(t, errs)
 		},
-		"should update limit with zero when negative value and return account": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+		"should close account": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
 			// 	given
-			givenAccount := domain.Account{
-				ActiveCard:     true,
-				AvailableLimit: 0,
-			}
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardClose}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(givenAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100, Closed: true}, account)
+			assert.Empty(t, errs)
+		},
+		"should return error when transition is not allowed": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardActivate}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(givenAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(
+				domain.ErrCardTransitionNotAllowed,
+				"can not activate a card that is active",
+				map[string]interface{}{"status": domain.CardActive, "action": domain.CardActivate},
+			)}
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when block has no reason": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardBlock}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(givenAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			_, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrBlockReasonRequired}, errs)
+		},
+		"should return error when account is closed": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardActivate}
+			givenClosedAccount := domain.Account{ID: "1", AvailableLimit: 100, Closed: true}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(givenClosedAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			assert.Equal(t, givenClosedAccount, account)
+			assert.Equal(t, []error{domain.ErrAccountClosed}, errs)
+		},
+		"should return error when account not initialized": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardActivate}
+			accountRepositoryMock.On("CommitCardChange", givenChange).Return(domain.Account{}, domain.ErrAccountNotFound)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeCard(givenChange)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.Equal(t, []error{domain.ErrAccountNotInitialized}, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			accountRepositoryMock := new(accountRepositoryMock)
+
+			run(t, accountRepositoryMock)
+
+			accountRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangeLimit(t *testing.T) {
+	givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40, TotalLimit: 100}
+
+	testCases := map[string]func(*testing.T, *accountRepositoryMock){
+		"should raise total and available limits": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 300}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeLimit(givenChange)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 240, TotalLimit: 300}, account)
+			assert.Empty(t, errs)
+		},
+		"should change daily and monthly limits": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenDailyLimit, givenMonthlyLimit := 50, 0
+			givenSpendLimitedAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40, TotalLimit: 100, DailyLimit: 20, MonthlyLimit: 80}
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 100, DailyLimit: &givenDailyLimit, MonthlyLimit: &givenMonthlyLimit}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenSpendLimitedAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeLimit(givenChange)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40, TotalLimit: 100, DailyLimit: 50}, account)
+			assert.Empty(t, errs)
+		},
+		"should keep daily and monthly limits when not given": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenSpendLimitedAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40, TotalLimit: 100, DailyLimit: 20, MonthlyLimit: 80}
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 300}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenSpendLimitedAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeLimit(givenChange)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 240, TotalLimit: 300, DailyLimit: 20, MonthlyLimit: 80}, account)
+			assert.Empty(t, errs)
+		},
+		"should lower total limit down to used limit": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 60}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeLimit(givenChange)
 
-			accountRepositoryMock.On("UpdateAccountLimit", 0)
-			accountRepositoryMock.On("FindAccount").Return(givenAccount, nil)
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 0, TotalLimit: 60}, account)
+			assert.Empty(t, errs)
+		},
+		"should return error when total limit is below used limit": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 50}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenAccount, nil)
 
 			accountService := NewAccountService(accountRepositoryMock)
 
 			// 	when
-			account := accountService.SetAccountLimit(-1)
+			account, errs := accountService.ChangeLimit(givenChange)
 
 			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrLimitBelowUsed, "total limit is below the used limit", map[string]interface{}{
+				"requested": 50,
+				"used":      60,
+			})}
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when daily or monthly limit is negative": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenDailyLimit, givenMonthlyLimit := -1, -2
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 100, DailyLimit: &givenDailyLimit, MonthlyLimit: &givenMonthlyLimit}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeLimit(givenChange)
+
+			// 	then
+			wantErrs := []error{
+				domain.NewViolation(domain.ErrLimitInvalid, "daily-limit is negative", map[string]interface{}{"limit": "daily-limit", "requested": -1}),
+				domain.NewViolation(domain.ErrLimitInvalid, "monthly-limit is negative", map[string]interface{}{"limit": "monthly-limit", "requested": -2}),
+			}
 			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when account is closed": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 300}
+			givenClosedAccount := domain.Account{ID: "1", AvailableLimit: 40, TotalLimit: 100, Closed: true}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(givenClosedAccount, nil)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			_, errs := accountService.ChangeLimit(givenChange)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrAccountClosed}, errs)
+		},
+		"should return error when account not initialized": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 300}
+			accountRepositoryMock.On("CommitLimitChange", givenChange).Return(domain.Account{}, domain.ErrAccountNotFound)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, errs := accountService.ChangeLimit(givenChange)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.Equal(t, []error{domain.ErrAccountNotInitialized}, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			accountRepositoryMock := new(accountRepositoryMock)
+
+			run(t, accountRepositoryMock)
+
+			accountRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	givenEvents := []domain.Event{
+		domain.NewAccountCreated(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}, time.Now().UTC()),
+	}
+
+	testCases := map[string]func(*testing.T, *accountRepositoryMock){
+		"should get account ledger with success": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("FindEvents", "1").Return(givenEvents)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			events, err := accountService.GetEvents("1")
+
+			// 	then
+			assert.Equal(t, givenEvents, events)
+			assert.NoError(t, err)
+		},
+		"should return error when account has no ledger": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("FindEvents", "1").Return([]domain.Event{})
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			events, err := accountService.GetEvents("1")
+
+			// 	then
+			assert.Empty(t, events)
+			assert.EqualError(t, err, domain.ErrAccountNotInitialized.Error())
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			accountRepositoryMock := new(accountRepositoryMock)
+
+			run(t, accountRepositoryMock)
+
+			accountRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetAccountAt(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenEvents := []domain.Event{
+		domain.NewAccountCreated(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100}, givenTime),
+		domain.NewTransactionAuthorized(domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}}, givenTime.Add(time.Minute)),
+		domain.NewTransactionRejected(domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 250}}, []error{domain.ErrInsufficientLimit}, givenTime.Add(2*time.Minute)),
+		domain.NewTotalLimitChanged(domain.LimitChange{AccountID: "1", TotalLimit: 150}, givenTime.Add(3*time.Minute)),
+	}
+
+	testCases := map[string]func(*testing.T, *accountRepositoryMock){
+		"should fold events recorded until given time": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("FindEvents", "1").Return(givenEvents)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.GetAccountAt("1", givenTime.Add(2*time.Minute))
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100}, account)
+			assert.NoError(t, err)
+		},
+		"should fold every event when given time is after ledger": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("FindEvents", "1").Return(givenEvents)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.GetAccountAt("1", givenTime.Add(time.Hour))
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 125, TotalLimit: 150}, account)
+			assert.NoError(t, err)
+		},
+		"should fold events from the account the trimmed history folded into": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenTrimmedEvents := append([]domain.Event{
+				domain.NewHistoryTrimmed(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100}, 2, givenTime.Add(time.Minute)),
+			}, givenEvents[2:]...)
+			accountRepositoryMock.On("FindEvents", "1").Return(givenTrimmedEvents)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.GetAccountAt("1", givenTime.Add(time.Hour))
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 125, TotalLimit: 150}, account)
+			assert.NoError(t, err)
+		},
+		"should return error when given time is before the trimmed history": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			givenTrimmedEvents := append([]domain.Event{
+				domain.NewHistoryTrimmed(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100}, 2, givenTime.Add(time.Minute)),
+			}, givenEvents[2:]...)
+			accountRepositoryMock.On("FindEvents", "1").Return(givenTrimmedEvents)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.GetAccountAt("1", givenTime)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.EqualError(t, err, domain.ErrAccountNotInitialized.Error())
+		},
+		"should return error when account was not created at given time": func(t *testing.T, accountRepositoryMock *accountRepositoryMock) {
+			// 	given
+			accountRepositoryMock.On("FindEvents", "1").Return(givenEvents)
+
+			accountService := NewAccountService(accountRepositoryMock)
+
+			// 	when
+			account, err := accountService.GetAccountAt("1", givenTime.Add(-time.Minute))
+
+			// 	then
+			assert.Empty(t, account)
+			assert.EqualError(t, err, domain.ErrAccountNotInitialized.Error())
 		},
 	}
 
@@ -0,0 +1,25 @@
+package service
+
+import "time"
+
+const (
+	// TimeBasisEvent checks the rule windows at the time the transactions were made, as sent by the client.
+	TimeBasisEvent = "event"
+	// TimeBasisProcessing checks the rule windows at the time the transactions are processed, so a client can not dodge
+	// them sending fake times.
+	TimeBasisProcessing = "processing"
+)
+
+type (
+	// Clock tells the current time to the time-based checks.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// SystemClock tells the current time of the system.
+	SystemClock struct{}
+)
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
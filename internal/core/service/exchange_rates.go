@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+// ExchangeRates holds how many major units of a target currency a major unit of a source currency buys, keyed by the
+// ISO 4217 codes of the source and of the target.
+type ExchangeRates map[string]map[string]float64
+
+// Convert returns the money in the given currency at the rate from its currency, or at the inverse of the rate to it.
+// ok tells whether there is any of them.
+func (r ExchangeRates) Convert(money domain.Money, currency string) (domain.Money, bool) {
+	if money.Currency == currency {
+		return money, true
+	}
+	if rate, ok := r[money.Currency][currency]; ok && rate > 0 {
+		return money.Convert(currency, rate), true
+	}
+	if rate, ok := r[currency][money.Currency]; ok && rate > 0 {
+		return money.Convert(currency, 1/rate), true
+	}
+	return domain.Money{}, false
+}
+
+// convertToAccount returns the money in the currency of the account, converted at the rates when it is in another one,
+// along with the money it was given in when converted. Money without a currency is in the account currency already. The
+// currency is not supported when it is not a currency code, when the account has no currency or when there is no rate
+// between both.
+func (r ExchangeRates) convertToAccount(account domain.Account, money domain.Money) (domain.Money, *domain.Money, error) {
+	if money.Currency == "" {
+		return money, nil, nil
+	}
+
+	converted, ok := r.Convert(money, account.Currency)
+	if !domain.ValidCurrency(money.Currency) || account.Currency == "" || !ok {
+		return money, nil, domain.NewViolation(
+			domain.ErrCurrencyNotSupported,
+			fmt.Sprintf("currency %s is not supported by the account", money.Currency),
+			map[string]interface{}{"currency": money.Currency, "account-currency": account.Currency},
+		)
+	}
+	if converted == money {
+		return converted, nil, nil
+	}
+	return converted, &money, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestExchangeRatesConvert(t *testing.T) {
+	givenRates := ExchangeRates{
+		"USD": {"BRL": 5.25, "JPY": 150},
+		"KWD": {"USD": 3.25},
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should convert money at the rate between currencies": func(t *testing.T) {
+			// 	when
+			money, ok := givenRates.Convert(domain.Money{Amount: 1999, Currency: "USD"}, "BRL")
+
+			// 	then
+			assert.True(t, ok)
+			assert.Equal(t, domain.Money{Amount: 10495, Currency: "BRL"}, money)
+		},
+		"should convert money at the inverse of the rate between currencies": func(t *testing.T) {
+			// 	when
+			money, ok := givenRates.Convert(domain.Money{Amount: 10500, Currency: "BRL"}, "USD")
+
+			// 	then
+			assert.True(t, ok)
+			assert.Equal(t, domain.Money{Amount: 2000, Currency: "USD"}, money)
+		},
+		"should convert between currencies of different minor units": func(t *testing.T) {
+			// 	when
+			yen, yenOK := givenRates.Convert(domain.Money{Amount: 1000, Currency: "USD"}, "JPY")
+			dollars, dollarsOK := givenRates.Convert(domain.Money{Amount: 1000, Currency: "KWD"}, "USD")
+
+			// 	then
+			assert.True(t, yenOK)
+			assert.Equal(t, domain.Money{Amount: 1500, Currency: "JPY"}, yen)
+			assert.True(t, dollarsOK)
+			assert.Equal(t, domain.Money{Amount: 325, Currency: "USD"}, dollars)
+		},
+		"should keep money in the same currency": func(t *testing.T) {
+			// 	when
+			money, ok := ExchangeRates(nil).Convert(domain.Money{Amount: 100, Currency: "BRL"}, "BRL")
+
+			// 	then
+			assert.True(t, ok)
+			assert.Equal(t, domain.Money{Amount: 100, Currency: "BRL"}, money)
+		},
+		"should not convert money without rate": func(t *testing.T) {
+			// 	when
+			_, ok := givenRates.Convert(domain.Money{Amount: 100, Currency: "EUR"}, "BRL")
+
+			// 	then
+			assert.False(t, ok)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+// DefaultHoldTTL is how long a hold reserves the account limit when it is neither captured nor released.
+const DefaultHoldTTL = 7 * 24 * time.Hour
+
+type (
+	HoldRepository interface {
+		// CommitHold atomically authorizes the hold over its account, the account transactions and declined
+		// transactions after the given time and the account spending, recording it as placed when there are no
+		// violations. Like every commit, it first expires the holds of the account past their expiration at the
+		// operation time. A hold with the id of one the account already has fails with domain.ErrHoldExists.
+		CommitHold(
+			hold domain.Hold,
+			after time.Time,
+			authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) (domain.Hold, []error),
+		) (domain.Account, []error, error)
+		// CommitCapture atomically validates the capture over its account and the hold it references, recording it
+		// when there are no violations.
+		CommitCapture(
+			capture domain.Capture,
+			validate func(account domain.Account, hold domain.Hold, found bool) (domain.Capture, []error),
+		) (domain.Account, []error, error)
+		// CommitRelease atomically validates the release over its account and the hold it references, recording it
+		// when there are no violations.
+		CommitRelease(
+			release domain.Release,
+			validate func(account domain.Account, hold domain.Hold, found bool) []error,
+		) (domain.Account, []error, error)
+	}
+
+	HoldService struct {
+		repository HoldRepository
+		rules      RuleRegistry
+		clock      Clock
+		timeBasis  string
+		ttl        time.Duration
+		rates      ExchangeRates
+	}
+)
+
+// NewHoldService returns the service placing holds for the given TTL, at the event or processing time as told by the
+// time basis, the processing time being told by the clock. Holds and captures in another currency than the one of their
+// account are converted at the given rates.
+func NewHoldService(
+	repository HoldRepository,
+	rules RuleRegistry,
+	clock Clock,
+	timeBasis string,
+	ttl time.Duration,
+	rates ExchangeRates,
+) HoldService {
+	return HoldService{
+		repository: repository,
+		rules:      rules,
+		clock:      clock,
+		timeBasis:  timeBasis,
+		ttl:        ttl,
+		rates:      rates,
+	}
+}
+
+// PlaceHold reserves the hold amount of the account limit until the hold is captured, released or expires after the
+// service TTL, judged against the time of later operations. Holds are converted to the currency of their account and
+// authorized by the same rules as transactions, but for the lock rules: a declined hold is not recorded as a decline,
+// so it neither counts toward them nor locks the card. On processing time the hold is placed at the current time,
+// whatever time it was sent with. The hold must have a positive amount and an id no other hold of the account has.
+func (s HoldService) PlaceHold(hold domain.Hold) (domain.Account, []error) {
+	if s.timeBasis == TimeBasisProcessing {
+		hold.CreatedAt = s.clock.Now().UTC()
+	}
+	hold.ExpiresAt = hold.CreatedAt.UTC().Add(s.ttl)
+	windowStart := hold.CreatedAt.UTC().Add(-s.rules.Window())
+
+	account, errs, err := s.repository.CommitHold(hold, windowStart, func(account domain.Account, pastTransactions []domain.Transaction, declines []domain.Transaction, spending domain.Spending) (domain.Hold, []error) {
+		if hold.ID == "" {
+			return hold, []error{domain.ErrHoldIDRequired}
+		}
+		if hold.Amount <= 0 {
+			return hold, []error{domain.NewViolation(domain.ErrHoldAmountInvalid, "amount must be positive", map[string]interface{}{
+				"requested": hold.Amount,
+			})}
+		}
+		money, original, err := s.rates.convertToAccount(account, hold.Money)
+		if err != nil {
+			return hold, []error{err}
+		}
+		converted := hold
+		converted.Money, converted.Original = money, original
+		return converted, s.rules.Evaluate(account, converted.Transaction(), pastTransactions, declines, spending)
+	})
+	if errors.Is(err, domain.ErrHoldExists) {
+		return account, []error{domain.ErrHoldAlreadyPlaced}
+	}
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
+	}
+
+	return account, errs
+}
+
+// CaptureHold settles a positive amount up to the held one as a final debit, freeing the rest of the hold. The amount
+// is converted to the currency of the account before it is compared with the held one. On processing time the capture
+// is made at the current time, so it can not be sent back in time to beat the hold expiration.
+func (s HoldService) CaptureHold(capture domain.Capture) (domain.Account, []error) {
+	if s.timeBasis == TimeBasisProcessing {
+		capture.CreatedAt = s.clock.Now().UTC()
+	}
+	account, errs, err := s.repository.CommitCapture(capture, func(account domain.Account, hold domain.Hold, found bool) (domain.Capture, []error) {
+		if errs := validateHeld(hold, found); len(errs) > 0 {
+			return capture, errs
+		}
+		if capture.Amount <= 0 {
+			return capture, []error{domain.NewViolation(domain.ErrCaptureAmountInvalid, "amount must be positive", map[string]interface{}{
+				"requested": capture.Amount,
+			})}
+		}
+		money, original, err := s.rates.convertToAccount(account, capture.Money)
+		if err != nil {
+			return capture, []error{err}
+		}
+		converted := capture
+		converted.Money, converted.Original = money, original
+		if converted.Amount > hold.Amount {
+			return converted, []error{domain.NewViolation(domain.ErrCaptureExceedsHold, "amount exceeds the held amount", map[string]interface{}{
+				"requested": converted.Amount,
+				"held":      hold.Amount,
+			})}
+		}
+		return converted, nil
+	})
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
+	}
+
+	return account, errs
+}
+
+// ReleaseHold frees the whole held amount, at the current time on processing time like CaptureHold.
+func (s HoldService) ReleaseHold(release domain.Release) (domain.Account, []error) {
+	if s.timeBasis == TimeBasisProcessing {
+		release.CreatedAt = s.clock.Now().UTC()
+	}
+	account, errs, err := s.repository.CommitRelease(release, func(_ domain.Account, hold domain.Hold, found bool) []error {
+		return validateHeld(hold, found)
+	})
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
+	}
+
+	return account, errs
+}
+
+func validateHeld(hold domain.Hold, found bool) []error {
+	switch {
+	case !found:
+		return []error{domain.ErrHoldNotFound}
+	case hold.Status == domain.HoldExpired:
+		return []error{domain.ErrHoldExpired}
+	case hold.Status != domain.HoldHeld:
+		return []error{domain.ErrHoldAlreadySettled}
+	}
+	return nil
+}
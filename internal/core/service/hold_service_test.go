@@ -0,0 +1,434 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestPlaceHold(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 12, 0, 0, 0, time.UTC)}
+	givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}
+	givenHold := domain.Hold{
+		ID:        "h1",
+		AccountID: "1",
+		Money:     domain.Money{Amount: 60},
+		Merchant:  "hotel",
+		CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+	}
+	givenPlacedHold := givenHold
+	givenPlacedHold.ExpiresAt = time.Date(2019, 02, 14, 10, 0, 0, 0, time.UTC)
+	windowStart := givenHold.CreatedAt.Add(-DefaultRules().Window())
+
+	testCases := map[string]func(*testing.T, *holdRepositoryMock){
+		"should reserve hold amount until expiration": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, windowStart).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenHold)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40}, account)
+			assert.Empty(t, errs)
+		},
+		"should return violations of transaction rules": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 50}
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, windowStart).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenHold)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.ElementsMatch(t, violationErrors(errs), []error{domain.ErrInsufficientLimit})
+		},
+		"should not lock card when declined hold reaches max declines": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 50}
+			givenDeclines := []domain.Transaction{
+				{AccountID: "1", Money: domain.Money{Amount: 900}, Merchant: "casino", CreatedAt: givenHold.CreatedAt.Add(-2 * time.Minute)},
+				{AccountID: "1", Money: domain.Money{Amount: 800}, Merchant: "casino", CreatedAt: givenHold.CreatedAt.Add(-1 * time.Minute)},
+			}
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, givenHold.CreatedAt.Add(-10*time.Minute)).Return(givenAccount, []domain.Transaction{}, nil, givenDeclines)
+
+			rulesConfig := DefaultRulesConfig()
+			rulesConfig.TooManyDeclinesMax = 2
+			rules, err := NewRules(rulesConfig)
+			assert.NoError(t, err)
+			holdService := NewHoldService(holdRepositoryMock, rules, givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenHold)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, []error{domain.ErrInsufficientLimit}, violationErrors(errs))
+			assert.Empty(t, holdRepositoryMock.recorded)
+		},
+		"should place hold at processing time on processing time basis": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenProcessedHold := givenHold
+			givenProcessedHold.CreatedAt = givenClock.now
+			givenProcessedHold.ExpiresAt = givenClock.now.Add(24 * time.Hour)
+			holdRepositoryMock.On("CommitHold", givenProcessedHold, givenClock.now.Add(-DefaultRules().Window())).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisProcessing, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenHold)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40}, account)
+			assert.Empty(t, errs)
+		},
+		"should convert hold to account currency": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 10000, Currency: "BRL"}
+			givenForeignHold := givenHold
+			givenForeignHold.Amount = 1000
+			givenForeignHold.Currency = "USD"
+			givenPlacedHold := givenForeignHold
+			givenPlacedHold.ExpiresAt = time.Date(2019, 02, 14, 10, 0, 0, 0, time.UTC)
+			wantHold := givenPlacedHold
+			wantHold.Amount = 5250
+			wantHold.Currency = "BRL"
+			wantHold.Original = &domain.Money{Amount: 1000, Currency: "USD"}
+			wantHold.Status = domain.HoldHeld
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, windowStart).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, ExchangeRates{"USD": {"BRL": 5.25}})
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenForeignHold)
+
+			// 	then
+			assert.Equal(t, 4750, account.AvailableLimit)
+			assert.Empty(t, errs)
+			assert.Equal(t, &wantHold, holdRepositoryMock.recorded[0].Hold)
+		},
+		"should return error when there is no rate to account currency": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 10000, Currency: "BRL"}
+			givenForeignHold := givenHold
+			givenForeignHold.Currency = "EUR"
+			givenPlacedHold := givenForeignHold
+			givenPlacedHold.ExpiresAt = time.Date(2019, 02, 14, 10, 0, 0, 0, time.UTC)
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, windowStart).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, ExchangeRates{"USD": {"BRL": 5.25}})
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenForeignHold)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, []error{domain.ErrCurrencyNotSupported}, violationErrors(errs))
+			assert.Empty(t, holdRepositoryMock.recorded)
+		},
+		"should return violation when amount is not positive": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenZeroHold := givenHold
+			givenZeroHold.Amount = 0
+			givenPlacedZeroHold := givenPlacedHold
+			givenPlacedZeroHold.Amount = 0
+			holdRepositoryMock.On("CommitHold", givenPlacedZeroHold, windowStart).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenZeroHold)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, []error{domain.ErrHoldAmountInvalid}, violationErrors(errs))
+			assert.Empty(t, holdRepositoryMock.recorded)
+		},
+		"should return violation when id is empty": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAnonymousHold := givenHold
+			givenAnonymousHold.ID = ""
+			givenPlacedAnonymousHold := givenPlacedHold
+			givenPlacedAnonymousHold.ID = ""
+			holdRepositoryMock.On("CommitHold", givenPlacedAnonymousHold, windowStart).Return(givenAccount, []domain.Transaction{}, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenAnonymousHold)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, []error{domain.ErrHoldIDRequired}, errs)
+			assert.Empty(t, holdRepositoryMock.recorded)
+		},
+		"should return violation when account already has hold with id": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, windowStart).Return(givenAccount, []domain.Transaction{}, domain.ErrHoldExists)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenHold)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, []error{domain.ErrHoldAlreadyPlaced}, errs)
+		},
+		"should return error when account not initialized": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitHold", givenPlacedHold, windowStart).Return(domain.Account{}, []domain.Transaction{}, domain.ErrAccountNotFound)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, 24*time.Hour, nil)
+
+			// 	when
+			account, errs := holdService.PlaceHold(givenHold)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ElementsMatch(t, errs, []error{domain.ErrAccountNotInitialized})
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			holdRepositoryMock := new(holdRepositoryMock)
+
+			run(t, holdRepositoryMock)
+
+			holdRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCaptureHold(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 12, 0, 0, 0, time.UTC)}
+	givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40}
+	givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 60}, Merchant: "hotel", Status: domain.HoldHeld}
+	givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 45}}
+
+	testCases := map[string]func(*testing.T, *holdRepositoryMock){
+		"should debit captured amount and free the rest of the hold": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 55}, account)
+			assert.Empty(t, errs)
+		},
+		"should capture at processing time on processing time basis": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenProcessedCapture := givenCapture
+			givenProcessedCapture.CreatedAt = givenClock.now
+			holdRepositoryMock.On("CommitCapture", givenProcessedCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisProcessing, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 55}, account)
+			assert.Empty(t, errs)
+		},
+		"should convert capture to account currency before comparing it with the hold": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 4400, Currency: "BRL"}
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 5600, Currency: "BRL"}, Merchant: "hotel", Status: domain.HoldHeld}
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 1000, Currency: "USD"}}
+			wantCapture := givenCapture
+			wantCapture.Amount = 5250
+			wantCapture.Currency = "BRL"
+			wantCapture.Original = &domain.Money{Amount: 1000, Currency: "USD"}
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, ExchangeRates{"USD": {"BRL": 5.25}})
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Equal(t, 4750, account.AvailableLimit)
+			assert.Empty(t, errs)
+			assert.Equal(t, &wantCapture, holdRepositoryMock.recorded[0].Capture)
+		},
+		"should return error when converted capture exceeds hold": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 4400, Currency: "BRL"}
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 5000, Currency: "BRL"}, Merchant: "hotel", Status: domain.HoldHeld}
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 1000, Currency: "USD"}}
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, ExchangeRates{"USD": {"BRL": 5.25}})
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			wantErrs := []error{domain.NewViolation(domain.ErrCaptureExceedsHold, "amount exceeds the held amount", map[string]interface{}{
+				"requested": 5250,
+				"held":      5000,
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when capture exceeds hold": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 61}}
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			wantErrs := []error{domain.NewViolation(domain.ErrCaptureExceedsHold, "amount exceeds the held amount", map[string]interface{}{
+				"requested": 61,
+				"held":      60,
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when capture amount is not positive": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 0}}
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			wantErrs := []error{domain.NewViolation(domain.ErrCaptureAmountInvalid, "amount must be positive", map[string]interface{}{
+				"requested": 0,
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when hold not found": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, domain.Hold{}, false, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			_, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.ElementsMatch(t, errs, []error{domain.ErrHoldNotFound})
+		},
+		"should return error when hold expired": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenHold := givenHold
+			givenHold.Status = domain.HoldExpired
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			_, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.ElementsMatch(t, errs, []error{domain.ErrHoldExpired})
+		},
+		"should return error when hold already settled": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenHold := givenHold
+			givenHold.Status = domain.HoldCaptured
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			_, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.ElementsMatch(t, errs, []error{domain.ErrHoldAlreadySettled})
+		},
+		"should return error when account not initialized": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitCapture", givenCapture).Return(domain.Account{}, domain.Hold{}, false, domain.ErrAccountNotFound)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.CaptureHold(givenCapture)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ElementsMatch(t, errs, []error{domain.ErrAccountNotInitialized})
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			holdRepositoryMock := new(holdRepositoryMock)
+
+			run(t, holdRepositoryMock)
+
+			holdRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReleaseHold(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 12, 0, 0, 0, time.UTC)}
+	givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40}
+	givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 60}, Merchant: "hotel", Status: domain.HoldHeld}
+	givenRelease := domain.Release{AccountID: "1", HoldID: "h1"}
+
+	testCases := map[string]func(*testing.T, *holdRepositoryMock){
+		"should free the whole hold": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			holdRepositoryMock.On("CommitRelease", givenRelease).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.ReleaseHold(givenRelease)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}, account)
+			assert.Empty(t, errs)
+		},
+		"should return error when hold already settled": func(t *testing.T, holdRepositoryMock *holdRepositoryMock) {
+			// 	given
+			givenHold := givenHold
+			givenHold.Status = domain.HoldReleased
+			holdRepositoryMock.On("CommitRelease", givenRelease).Return(givenAccount, givenHold, true, nil)
+
+			holdService := NewHoldService(holdRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, DefaultHoldTTL, nil)
+
+			// 	when
+			account, errs := holdService.ReleaseHold(givenRelease)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.ElementsMatch(t, errs, []error{domain.ErrHoldAlreadySettled})
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			holdRepositoryMock := new(holdRepositoryMock)
+
+			run(t, holdRepositoryMock)
+
+			holdRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
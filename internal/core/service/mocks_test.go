@@ -16,38 +16,273 @@ func (mock *accountRepositoryMock) SaveAccount(account domain.Account) (domain.A
 	return args.Get(0).(domain.Account), args.Error(1)
 }
 
-func (mock *accountRepositoryMock) FindAccount() (domain.Account, error) {
-	args := mock.Called()
+func (mock *accountRepositoryMock) FindAccount(accountID string) (domain.Account, error) {
+	args := mock.Called(accountID)
 	return args.Get(0).(domain.Account), args.Error(1)
 }
 
-func (mock *accountRepositoryMock) UpdateAccountLimit(newAvailableLimit int) {
-	mock.Called(newAvailableLimit)
+func (mock *accountRepositoryMock) FindEvents(accountID string) []domain.Event {
+	args := mock.Called(accountID)
+	return args.Get(0).([]domain.Event)
+}
+
+// CommitCardChange validates over the account given to Return, changing the card like a repository would.
+func (mock *accountRepositoryMock) CommitCardChange(
+	change domain.CardChange,
+	validate func(domain.Account) []error,
+) (domain.Account, []error, error) {
+	args := mock.Called(change)
+	if err := args.Error(1); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account := args.Get(0).(domain.Account)
+	if errs := validate(account); len(errs) > 0 {
+		return account, errs, nil
+	}
+	return account.Apply(domain.NewCardChanged(change, time.Now().UTC())), []error{}, nil
+}
+
+// CommitLimitChange validates over the account given to Return, changing the limit like a repository would.
+func (mock *accountRepositoryMock) CommitLimitChange(
+	change domain.LimitChange,
+	validate func(domain.Account) []error,
+) (domain.Account, []error, error) {
+	args := mock.Called(change)
+	if err := args.Error(1); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account := args.Get(0).(domain.Account)
+	if errs := validate(account); len(errs) > 0 {
+		return account, errs, nil
+	}
+	return account.Apply(domain.NewTotalLimitChanged(change, time.Now().UTC())), []error{}, nil
 }
 
 type transactionRepositoryMock struct {
 	mock.Mock
+	// recorded holds the events the commits record, in order.
+	recorded []domain.Event
 }
 
-func (mock *transactionRepositoryMock) SaveTransaction(transaction domain.Transaction) {
-	mock.Called(transaction)
+func (mock *transactionRepositoryMock) FindAccount(accountID string) (domain.Account, error) {
+	args := mock.Called(accountID)
+	return args.Get(0).(domain.Account), args.Error(1)
 }
 
-func (mock *transactionRepositoryMock) FindTransactionsAfter(time time.Time) []domain.Transaction {
-	args := mock.Called(time)
+func (mock *transactionRepositoryMock) FindTransactionsAfter(accountID string, time time.Time) []domain.Transaction {
+	args := mock.Called(accountID, time)
 	return args.Get(0).([]domain.Transaction)
 }
 
-type accountServicerMock struct {
+// CommitTransaction authorizes over the account and transactions given to Return, the transactions being the whole
+// account spending, applying the authorized transaction, or the card change of a rejected one, to the account like a
+// repository would.
+func (mock *transactionRepositoryMock) CommitTransaction(
+	transaction domain.Transaction,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+) (domain.Account, []error, error) {
+	args := mock.Called(transaction, after)
+	if err := args.Error(2); err != nil {
+		return args.Get(0).(domain.Account), nil, err
+	}
+
+	account := args.Get(0).(domain.Account)
+	history := args.Get(1).([]domain.Transaction)
+	authorization := authorize(account, history, declinesOf(args), historySpending(history))
+	if errs := authorization.Violations; len(errs) > 0 {
+		mock.recorded = append(mock.recorded, domain.NewTransactionRejected(authorization.Transaction, errs, time.Now().UTC()))
+		if authorization.CardChange != nil {
+			event := domain.NewCardChanged(*authorization.CardChange, time.Now().UTC())
+			mock.recorded = append(mock.recorded, event)
+			account = account.Apply(event)
+		}
+		return account, errs, nil
+	}
+	event := domain.NewTransactionAuthorized(authorization.Transaction, time.Now().UTC())
+	mock.recorded = append(mock.recorded, event)
+	return account.Apply(event), []error{}, nil
+}
+
+// SimulateTransaction authorizes over the account and transactions given to Return like CommitTransaction.
+func (mock *transactionRepositoryMock) SimulateTransaction(
+	transaction domain.Transaction,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+) (domain.Account, []error, error) {
+	args := mock.Called(transaction, after)
+	if err := args.Error(2); err != nil {
+		return args.Get(0).(domain.Account), nil, err
+	}
+
+	account := args.Get(0).(domain.Account)
+	history := args.Get(1).([]domain.Transaction)
+	authorization := authorize(account, history, declinesOf(args), historySpending(history))
+	if errs := authorization.Violations; len(errs) > 0 {
+		return account, errs, nil
+	}
+	return account.Apply(domain.NewTransactionAuthorized(authorization.Transaction, time.Now().UTC())), []error{}, nil
+}
+
+// CommitReversal validates over the account and transaction given to Return, applying the reversal to the account like
+// a repository would.
+func (mock *transactionRepositoryMock) CommitReversal(
+	reversal domain.Reversal,
+	validate func(account domain.Account, transaction domain.Transaction, found bool) []error,
+) (domain.Account, []error, error) {
+	args := mock.Called(reversal)
+	if err := args.Error(3); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, transaction, found := args.Get(0).(domain.Account), args.Get(1).(domain.Transaction), args.Bool(2)
+	if errs := validate(account, transaction, found); len(errs) > 0 {
+		return account, errs, nil
+	}
+	return account.Apply(domain.NewTransactionReversed(transaction, reversal, time.Now().UTC())), []error{}, nil
+}
+
+func (mock *transactionRepositoryMock) FindDecisions(accountID string, filter domain.DecisionFilter) ([]domain.Decision, error) {
+	args := mock.Called(accountID, filter)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return args.Get(0).([]domain.Decision), nil
+}
+
+type ruleMock struct {
 	mock.Mock
 }
 
-func (mock *accountServicerMock) GetAccount() (domain.Account, error) {
+func (mock *ruleMock) Name() string {
 	args := mock.Called()
-	return args.Get(0).(domain.Account), args.Error(1)
+	return args.String(0)
+}
+
+func (mock *ruleMock) Window() time.Duration {
+	args := mock.Called()
+	return args.Get(0).(time.Duration)
+}
+
+func (mock *ruleMock) Evaluate(account domain.Account, transaction domain.Transaction, history []domain.Transaction) []error {
+	args := mock.Called(account, transaction, history)
+	return args.Get(0).([]error)
+}
+
+type holdRepositoryMock struct {
+	mock.Mock
+	// recorded holds the events the commits record, in order.
+	recorded []domain.Event
+}
+
+// CommitHold authorizes over the account and transactions given to Return, the transactions being the whole account
+// spending, placing the hold like a repository would.
+func (mock *holdRepositoryMock) CommitHold(
+	hold domain.Hold,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) (domain.Hold, []error),
+) (domain.Account, []error, error) {
+	args := mock.Called(hold, after)
+	if err := args.Error(2); err != nil {
+		return args.Get(0).(domain.Account), nil, err
+	}
+
+	account := args.Get(0).(domain.Account)
+	history := args.Get(1).([]domain.Transaction)
+	placed, errs := authorize(account, history, declinesOf(args), historySpending(history))
+	if len(errs) > 0 {
+		return account, errs, nil
+	}
+	event := domain.NewHoldPlaced(placed, time.Now().UTC())
+	mock.recorded = append(mock.recorded, event)
+	return account.Apply(event), []error{}, nil
+}
+
+// CommitCapture validates over the account and hold given to Return, capturing the hold like a repository would.
+func (mock *holdRepositoryMock) CommitCapture(
+	capture domain.Capture,
+	validate func(account domain.Account, hold domain.Hold, found bool) (domain.Capture, []error),
+) (domain.Account, []error, error) {
+	args := mock.Called(capture)
+	if err := args.Error(3); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, hold, found := args.Get(0).(domain.Account), args.Get(1).(domain.Hold), args.Bool(2)
+	captured, errs := validate(account, hold, found)
+	if len(errs) > 0 {
+		return account, errs, nil
+	}
+	event := domain.NewHoldCaptured(hold, captured, time.Now().UTC())
+	mock.recorded = append(mock.recorded, event)
+	return account.Apply(event), []error{}, nil
+}
+
+// CommitRelease validates over the account and hold given to Return, releasing the hold like a repository would.
+func (mock *holdRepositoryMock) CommitRelease(
+	release domain.Release,
+	validate func(account domain.Account, hold domain.Hold, found bool) []error,
+) (domain.Account, []error, error) {
+	args := mock.Called(release)
+	if err := args.Error(3); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, hold, found := args.Get(0).(domain.Account), args.Get(1).(domain.Hold), args.Bool(2)
+	if errs := validate(account, hold, found); len(errs) > 0 {
+		return account, errs, nil
+	}
+	return account.Apply(domain.NewHoldReleased(hold, release, time.Now().UTC())), []error{}, nil
+}
+
+// historySpending sums the transactions given to a repository mock as the account spending, leaving out reversed ones.
+type historySpending []domain.Transaction
+
+func (s historySpending) SpentBetween(from time.Time, to time.Time) int {
+	spent := 0
+	for _, transaction := range s {
+		if !transaction.Reversed && !transaction.CreatedAt.Before(from) && transaction.CreatedAt.Before(to) {
+			spent += transaction.Amount
+		}
+	}
+	return spent
+}
+
+func (s historySpending) AtMerchant(merchant string) domain.Spending {
+	transactions := historySpending{}
+	for _, transaction := range s {
+		if transaction.Merchant == merchant {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions
+}
+
+func (s historySpending) InCategory(mcc string) domain.Spending {
+	transactions := historySpending{}
+	for _, transaction := range s {
+		if transaction.MCC == mcc {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions
+}
+
+// declinesOf returns the declined transactions given to Return after the error of a repository mock, if any.
+func declinesOf(args mock.Arguments) []domain.Transaction {
+	if len(args) < 4 {
+		return nil
+	}
+	return args.Get(3).([]domain.Transaction)
+}
+
+// fakeClock tells always the same time, so time-based checks are deterministic.
+type fakeClock struct {
+	now time.Time
 }
 
-func (mock *accountServicerMock) SetAccountLimit(newAvailableLimit int) domain.Account {
-	args := mock.Called(newAvailableLimit)
-	return args.Get(0).(domain.Account)
+func (c fakeClock) Now() time.Time {
+	return c.now
 }
@@ -0,0 +1,534 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+const (
+	MatchAmount   = "amount"
+	MatchMerchant = "merchant"
+)
+
+const (
+	defaultHighFrequencyWindow          = 2 * time.Minute
+	defaultHighFrequencyMaxTransactions = 3
+	defaultDoubleTransactionWindow      = 2 * time.Minute
+	defaultTooManyDeclinesWindow        = 10 * time.Minute
+)
+
+type (
+	// RulesConfig holds the tunable parameters of the standard authorization rules.
+	RulesConfig struct {
+		HighFrequencyWindow          time.Duration
+		HighFrequencyMaxTransactions int
+		// HighFrequencyCountAttempts counts the declined transactions toward the high frequency limit along with the
+		// authorized ones.
+		HighFrequencyCountAttempts bool
+		TooManyDeclinesWindow      time.Duration
+		// TooManyDeclinesMax is the number of declined transactions within TooManyDeclinesWindow that locks the card, zero
+		// disables the lock.
+		TooManyDeclinesMax      int
+		DoubleTransactionWindow time.Duration
+		// DoubleTransactionMatch lists the transaction fields that must be equal for two transactions to be doubled.
+		DoubleTransactionMatch []string
+		// MerchantLimits and MCCLimits cap the spending at merchants and merchant categories, keyed by merchant and MCC.
+		MerchantLimits map[string]SpendingLimit
+		MCCLimits      map[string]SpendingLimit
+		// Location is where the calendar days and months of the spending limits start and end.
+		Location *time.Location
+		// ClockSkew and MaxTransactionAge bound how far in the future and in the past of the processing time a
+		// transaction can be made, a zero bound is not checked.
+		ClockSkew         time.Duration
+		MaxTransactionAge time.Duration
+		// Clock tells the processing time the transaction times are bounded around.
+		Clock Clock
+	}
+
+	// Rule checks an incoming transaction against the account and the transactions it made within Window,
+	// returning the violations found, if any.
+	Rule interface {
+		Name() string
+		Window() time.Duration
+		Evaluate(account domain.Account, transaction domain.Transaction, history []domain.Transaction) []error
+	}
+
+	// SpendingRule is a Rule evaluated over the amounts the account spent, summed by the repository, rather than over its
+	// history.
+	SpendingRule interface {
+		Rule
+		EvaluateSpending(account domain.Account, transaction domain.Transaction, spending domain.Spending) []error
+	}
+
+	// DeclineRule is a Rule evaluated over the transactions the account had declined within Window as well as over its
+	// history.
+	DeclineRule interface {
+		Rule
+		EvaluateDeclines(account domain.Account, transaction domain.Transaction, history []domain.Transaction, declines []domain.Transaction) []error
+	}
+
+	// LockRule is a Rule evaluated over a transaction the other rules declined, along with the transactions the account
+	// had declined within Window, its violations locking the card of the account. It finds no violation on its own.
+	LockRule interface {
+		Rule
+		EvaluateLock(account domain.Account, transaction domain.Transaction, declines []domain.Transaction) []error
+	}
+
+	// HaltingRule is a Rule that, when violated, stops the evaluation of the rules registered after it.
+	HaltingRule interface {
+		Rule
+		Halts() bool
+	}
+
+	// RuleRegistry holds the rules evaluated for each transaction, in registration order.
+	RuleRegistry struct {
+		rules []Rule
+	}
+
+	ActiveCardRule struct{}
+
+	InsufficientLimitRule struct{}
+
+	HighFrequencySmallIntervalRule struct {
+		window          time.Duration
+		maxTransactions int
+		countAttempts   bool
+	}
+
+	// TooManyDeclinesRule locks the card of an account when the transaction declined makes too many transactions
+	// declined within the window.
+	TooManyDeclinesRule struct {
+		window      time.Duration
+		maxDeclines int
+	}
+
+	DoubleTransactionRule struct {
+		window time.Duration
+		match  []string
+	}
+
+	// TransactionTimeRule rejects transactions made too far in the future or in the past of the processing time.
+	TransactionTimeRule struct {
+		clockSkew time.Duration
+		maxAge    time.Duration
+		clock     Clock
+	}
+)
+
+func DefaultRulesConfig() RulesConfig {
+	return RulesConfig{
+		HighFrequencyWindow:          defaultHighFrequencyWindow,
+		HighFrequencyMaxTransactions: defaultHighFrequencyMaxTransactions,
+		TooManyDeclinesWindow:        defaultTooManyDeclinesWindow,
+		DoubleTransactionWindow:      defaultDoubleTransactionWindow,
+		DoubleTransactionMatch:       []string{MatchAmount, MatchMerchant},
+		Location:                     time.UTC,
+		Clock:                        SystemClock{},
+	}
+}
+
+// NewRules returns the registry with the standard authorization rules tuned by the given config.
+func NewRules(config RulesConfig) (RuleRegistry, error) {
+	if config.HighFrequencyWindow <= 0 || config.DoubleTransactionWindow <= 0 {
+		return RuleRegistry{}, fmt.Errorf("rule windows must be positive")
+	}
+	if config.HighFrequencyMaxTransactions <= 0 {
+		return RuleRegistry{}, fmt.Errorf("high frequency max transactions must be positive")
+	}
+	if config.TooManyDeclinesMax < 0 {
+		return RuleRegistry{}, fmt.Errorf("too many declines max must not be negative")
+	}
+	if config.TooManyDeclinesMax > 0 && config.TooManyDeclinesWindow <= 0 {
+		return RuleRegistry{}, fmt.Errorf("rule windows must be positive")
+	}
+	if len(config.DoubleTransactionMatch) == 0 {
+		return RuleRegistry{}, fmt.Errorf("double transaction match fields must not be empty")
+	}
+	for _, field := range config.DoubleTransactionMatch {
+		if field != MatchAmount && field != MatchMerchant {
+			return RuleRegistry{}, fmt.Errorf("unknown double transaction match field %q", field)
+		}
+	}
+
+	for _, limits := range []map[string]SpendingLimit{config.MerchantLimits, config.MCCLimits} {
+		for _, limit := range limits {
+			if limit.PerTransaction < 0 || limit.Daily < 0 || limit.Monthly < 0 {
+				return RuleRegistry{}, fmt.Errorf("spending limits must not be negative")
+			}
+		}
+	}
+
+	if config.ClockSkew < 0 || config.MaxTransactionAge < 0 {
+		return RuleRegistry{}, fmt.Errorf("transaction time tolerances must not be negative")
+	}
+
+	location := config.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	registry := NewRuleRegistry()
+	if config.ClockSkew > 0 || config.MaxTransactionAge > 0 {
+		registry.Register(NewTransactionTimeRule(config.ClockSkew, config.MaxTransactionAge, clock))
+	}
+	registry.Register(ActiveCardRule{})
+	if config.TooManyDeclinesMax > 0 {
+		registry.Register(NewTooManyDeclinesRule(config.TooManyDeclinesWindow, config.TooManyDeclinesMax))
+	}
+	registry.Register(
+		InsufficientLimitRule{},
+		NewHighFrequencySmallIntervalRule(config.HighFrequencyWindow, config.HighFrequencyMaxTransactions, config.HighFrequencyCountAttempts),
+		NewDoubleTransactionRule(config.DoubleTransactionWindow, config.DoubleTransactionMatch...),
+		NewSpendLimitRule(location),
+	)
+	for _, merchant := range sortedKeys(config.MerchantLimits) {
+		registry.Register(NewMerchantLimitRule(merchant, config.MerchantLimits[merchant], location))
+	}
+	for _, mcc := range sortedKeys(config.MCCLimits) {
+		registry.Register(NewMCCLimitRule(mcc, config.MCCLimits[mcc], location))
+	}
+	return registry, nil
+}
+
+func NewRuleRegistry(rules ...Rule) RuleRegistry {
+	return RuleRegistry{rules: rules}
+}
+
+// DefaultRules returns the registry with the standard authorization rules.
+func DefaultRules() RuleRegistry {
+	rules, _ := NewRules(DefaultRulesConfig())
+	return rules
+}
+
+// Register appends rules to the end of the registry.
+func (r *RuleRegistry) Register(rules ...Rule) {
+	r.rules = append(r.rules, rules...)
+}
+
+// Remove drops every rule with the given name from the registry.
+func (r *RuleRegistry) Remove(name string) {
+	rules := make([]Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		if rule.Name() != name {
+			rules = append(rules, rule)
+		}
+	}
+	r.rules = rules
+}
+
+func (r RuleRegistry) Rules() []Rule {
+	return append([]Rule{}, r.rules...)
+}
+
+// Window returns the widest window among the registered rules.
+func (r RuleRegistry) Window() time.Duration {
+	var window time.Duration
+	for _, rule := range r.rules {
+		if rule.Window() > window {
+			window = rule.Window()
+		}
+	}
+	return window
+}
+
+// EvaluateLocks checks the transaction the rules declined against every lock rule, over the given declined
+// transactions.
+func (r RuleRegistry) EvaluateLocks(
+	account domain.Account,
+	transaction domain.Transaction,
+	declines []domain.Transaction,
+) []error {
+	errors := []error{}
+	for _, rule := range r.rules {
+		if lockRule, ok := rule.(LockRule); ok {
+			errors = append(errors, lockRule.EvaluateLock(account, transaction, declines)...)
+		}
+	}
+	return errors
+}
+
+// Evaluate checks the transaction against every rule, spending rules being checked over the given spending when there
+// is one and decline rules over the given declined transactions. Lock rules are left to EvaluateLocks.
+func (r RuleRegistry) Evaluate(
+	account domain.Account,
+	transaction domain.Transaction,
+	history []domain.Transaction,
+	declines []domain.Transaction,
+	spending domain.Spending,
+) []error {
+	errors := []error{}
+	for _, rule := range r.rules {
+		var violations []error
+		if spendingRule, ok := rule.(SpendingRule); ok && spending != nil {
+			violations = spendingRule.EvaluateSpending(account, transaction, spending)
+		} else if declineRule, ok := rule.(DeclineRule); ok {
+			violations = declineRule.EvaluateDeclines(account, transaction, history, declines)
+		} else {
+			violations = rule.Evaluate(account, transaction, history)
+		}
+		errors = append(errors, violations...)
+
+		if haltingRule, ok := rule.(HaltingRule); ok && haltingRule.Halts() && len(violations) > 0 {
+			break
+		}
+	}
+	return errors
+}
+
+func (ActiveCardRule) Name() string {
+	return "active-card"
+}
+
+func (ActiveCardRule) Window() time.Duration {
+	return 0
+}
+
+func (ActiveCardRule) Halts() bool {
+	return true
+}
+
+func (ActiveCardRule) Evaluate(account domain.Account, _ domain.Transaction, _ []domain.Transaction) []error {
+	switch account.CardStatus() {
+	case domain.CardClosed:
+		return []error{domain.NewViolation(domain.ErrAccountClosed, "account is closed", nil)}
+	case domain.CardBlocked:
+		return []error{domain.NewViolation(domain.ErrCardBlocked, "account card is blocked", map[string]interface{}{
+			"reason": account.BlockReason,
+		})}
+	case domain.CardInactive:
+		return []error{domain.NewViolation(domain.ErrCardNotActive, "account card is not active", nil)}
+	}
+	return nil
+}
+
+func (InsufficientLimitRule) Name() string {
+	return "insufficient-limit"
+}
+
+func (InsufficientLimitRule) Window() time.Duration {
+	return 0
+}
+
+func (InsufficientLimitRule) Evaluate(account domain.Account, transaction domain.Transaction, _ []domain.Transaction) []error {
+	if account.AvailableLimit < transaction.Amount {
+		return []error{domain.NewViolation(domain.ErrInsufficientLimit, "amount exceeds the available limit", map[string]interface{}{
+			"requested": transaction.Amount,
+			"available": account.AvailableLimit,
+		})}
+	}
+	return nil
+}
+
+// NewHighFrequencySmallIntervalRule returns the rule allowing up to maxTransactions within the window, counting the
+// declined transactions as well when countAttempts is set, so an account probed with declined transactions is throttled
+// too.
+func NewHighFrequencySmallIntervalRule(window time.Duration, maxTransactions int, countAttempts bool) HighFrequencySmallIntervalRule {
+	return HighFrequencySmallIntervalRule{
+		window:          window,
+		maxTransactions: maxTransactions,
+		countAttempts:   countAttempts,
+	}
+}
+
+func (HighFrequencySmallIntervalRule) Name() string {
+	return "high-frequency-small-interval"
+}
+
+func (r HighFrequencySmallIntervalRule) Window() time.Duration {
+	return r.window
+}
+
+func (r HighFrequencySmallIntervalRule) Evaluate(account domain.Account, transaction domain.Transaction, history []domain.Transaction) []error {
+	return r.EvaluateDeclines(account, transaction, history, nil)
+}
+
+func (r HighFrequencySmallIntervalRule) EvaluateDeclines(
+	_ domain.Account,
+	transaction domain.Transaction,
+	history []domain.Transaction,
+	declines []domain.Transaction,
+) []error {
+	pastTransactions := transactionsWithin(r.window, transaction, history)
+	if r.countAttempts {
+		pastTransactions = append(pastTransactions, transactionsWithin(r.window, transaction, declines)...)
+	}
+	if len(pastTransactions) >= r.maxTransactions {
+		return []error{domain.NewViolation(
+			domain.ErrHighFrequencySmallInterval,
+			fmt.Sprintf("%d transactions within %s", len(pastTransactions), r.window),
+			map[string]interface{}{
+				"transactions":     len(pastTransactions),
+				"max-transactions": r.maxTransactions,
+				"window":           r.window.String(),
+			},
+		)}
+	}
+	return nil
+}
+
+func NewTooManyDeclinesRule(window time.Duration, maxDeclines int) TooManyDeclinesRule {
+	return TooManyDeclinesRule{
+		window:      window,
+		maxDeclines: maxDeclines,
+	}
+}
+
+func (TooManyDeclinesRule) Name() string {
+	return "too-many-declines"
+}
+
+func (r TooManyDeclinesRule) Window() time.Duration {
+	return r.window
+}
+
+// Evaluate is a lock rule, a transaction is never declined by it.
+func (TooManyDeclinesRule) Evaluate(_ domain.Account, _ domain.Transaction, _ []domain.Transaction) []error {
+	return nil
+}
+
+// EvaluateLock counts the declined transaction along with the ones declined within the window around it, so the card
+// is locked by the decline reaching the max declines.
+func (r TooManyDeclinesRule) EvaluateLock(
+	_ domain.Account,
+	transaction domain.Transaction,
+	declines []domain.Transaction,
+) []error {
+	count := len(transactionsWithin(r.window, transaction, declines)) + 1
+	if count >= r.maxDeclines {
+		return []error{domain.NewViolation(
+			domain.ErrTooManyDeclines,
+			fmt.Sprintf("%d declined transactions within %s", count, r.window),
+			map[string]interface{}{
+				"declines":     count,
+				"max-declines": r.maxDeclines,
+				"window":       r.window.String(),
+			},
+		)}
+	}
+	return nil
+}
+
+// NewDoubleTransactionRule returns the rule matching transactions by the given fields, amount and merchant by default.
+func NewDoubleTransactionRule(window time.Duration, match ...string) DoubleTransactionRule {
+	if len(match) == 0 {
+		match = []string{MatchAmount, MatchMerchant}
+	}
+	return DoubleTransactionRule{
+		window: window,
+		match:  match,
+	}
+}
+
+func (DoubleTransactionRule) Name() string {
+	return "double-transaction"
+}
+
+func (r DoubleTransactionRule) Window() time.Duration {
+	return r.window
+}
+
+func (r DoubleTransactionRule) Evaluate(_ domain.Account, transaction domain.Transaction, history []domain.Transaction) []error {
+	for _, pastTransaction := range transactionsWithin(r.window, transaction, history) {
+		if r.matches(pastTransaction, transaction) {
+			return []error{domain.NewViolation(
+				domain.ErrDoubleTransaction,
+				fmt.Sprintf("matches a transaction within %s", r.window),
+				map[string]interface{}{
+					"transaction": pastTransaction,
+					"match":       r.match,
+					"window":      r.window.String(),
+				},
+			)}
+		}
+	}
+	return nil
+}
+
+func (r DoubleTransactionRule) matches(pastTransaction domain.Transaction, transaction domain.Transaction) bool {
+	for _, field := range r.match {
+		switch field {
+		case MatchAmount:
+			if pastTransaction.Amount != transaction.Amount {
+				return false
+			}
+		case MatchMerchant:
+			if pastTransaction.Merchant != transaction.Merchant {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewTransactionTimeRule returns the rule allowing transactions up to clockSkew in the future and maxAge in the past of
+// the current time told by the clock, a zero bound is not checked.
+func NewTransactionTimeRule(clockSkew time.Duration, maxAge time.Duration, clock Clock) TransactionTimeRule {
+	return TransactionTimeRule{
+		clockSkew: clockSkew,
+		maxAge:    maxAge,
+		clock:     clock,
+	}
+}
+
+func (TransactionTimeRule) Name() string {
+	return "transaction-time"
+}
+
+func (TransactionTimeRule) Window() time.Duration {
+	return 0
+}
+
+func (TransactionTimeRule) Halts() bool {
+	return true
+}
+
+func (r TransactionTimeRule) Evaluate(_ domain.Account, transaction domain.Transaction, _ []domain.Transaction) []error {
+	now := r.clock.Now().UTC()
+	metadata := map[string]interface{}{
+		"time": transaction.CreatedAt.UTC().Format(time.RFC3339),
+		"now":  now.Format(time.RFC3339),
+	}
+
+	switch {
+	case r.clockSkew > 0 && transaction.CreatedAt.After(now.Add(r.clockSkew)):
+		metadata["clock-skew"] = r.clockSkew.String()
+		return []error{domain.NewViolation(domain.ErrTransactionTimeInvalid, "transaction time is too far in the future", metadata)}
+	case r.maxAge > 0 && transaction.CreatedAt.Before(now.Add(-r.maxAge)):
+		metadata["max-age"] = r.maxAge.String()
+		return []error{domain.NewViolation(domain.ErrTransactionTimeInvalid, "transaction time is too far in the past", metadata)}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of the spending limits in order, so rules are registered the same way on every run.
+func sortedKeys(limits map[string]SpendingLimit) []string {
+	keys := make([]string, 0, len(limits))
+	for key := range limits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// transactionsWithin returns the transactions made less than the window before or after the transaction, so one
+// arriving out of order is checked against the transactions around its own time only.
+func transactionsWithin(window time.Duration, transaction domain.Transaction, history []domain.Transaction) []domain.Transaction {
+	after := transaction.CreatedAt.UTC().Add(-window)
+	before := transaction.CreatedAt.UTC().Add(window)
+
+	transactions := []domain.Transaction{}
+	for _, pastTransaction := range history {
+		if pastTransaction.CreatedAt.After(after) && pastTransaction.CreatedAt.Before(before) {
+			transactions = append(transactions, pastTransaction)
+		}
+	}
+	return transactions
+}
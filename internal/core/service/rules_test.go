@@ -0,0 +1,642 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestRuleRegistry(t *testing.T) {
+	givenAccount := domain.Account{
+		ID:             "1",
+		ActiveCard:     true,
+		AvailableLimit: 100,
+	}
+	givenTransaction := domain.Transaction{
+		AccountID: "1",
+		Money:     domain.Money{Amount: 25},
+		Merchant:  "ifood",
+		CreatedAt: time.Now().UTC(),
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should evaluate rules in registration order": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			registry := NewRuleRegistry(
+				NewDoubleTransactionRule(2*time.Minute),
+				InsufficientLimitRule{},
+			)
+
+			// 	when
+			errs := registry.Evaluate(domain.Account{ActiveCard: true, AvailableLimit: 10}, givenTransaction, givenHistory, nil, historySpending(givenHistory))
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrDoubleTransaction, domain.ErrInsufficientLimit}, violationErrors(errs))
+		},
+		"should stop evaluation when halting rule is violated": func(t *testing.T) {
+			// 	given
+			registry := NewRuleRegistry(ActiveCardRule{}, InsufficientLimitRule{})
+
+			// 	when
+			errs := registry.Evaluate(domain.Account{ActiveCard: false, AvailableLimit: 10}, givenTransaction, nil, nil, nil)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrCardNotActive}, violationErrors(errs))
+		},
+		"should return empty errors when no rule is violated": func(t *testing.T) {
+			// 	given
+			registry := DefaultRules()
+
+			// 	when
+			errs := registry.Evaluate(givenAccount, givenTransaction, []domain.Transaction{}, nil, historySpending{})
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should evaluate spending rules over given spending": func(t *testing.T) {
+			// 	given
+			givenSpending := historySpending{{Money: domain.Money{Amount: 80}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)}}
+			registry := NewRuleRegistry(NewSpendLimitRule(time.UTC))
+
+			// 	when
+			errs := registry.Evaluate(domain.Account{ActiveCard: true, AvailableLimit: 100, DailyLimit: 100}, givenTransaction, nil, nil, givenSpending)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrDailyLimitExceeded}, violationErrors(errs))
+		},
+		"should register and remove rules by name": func(t *testing.T) {
+			// 	given
+			registry := NewRuleRegistry(ActiveCardRule{})
+
+			// 	when
+			registry.Register(InsufficientLimitRule{}, NewDoubleTransactionRule(time.Minute))
+			registry.Remove("active-card")
+
+			// 	then
+			wantRules := []Rule{InsufficientLimitRule{}, NewDoubleTransactionRule(time.Minute)}
+			assert.Equal(t, wantRules, registry.Rules())
+		},
+		"should return widest window of registered rules": func(t *testing.T) {
+			// 	given
+			registry := NewRuleRegistry(
+				ActiveCardRule{},
+				NewHighFrequencySmallIntervalRule(2*time.Minute, 3, false),
+				NewDoubleTransactionRule(5*time.Minute),
+			)
+
+			// 	when
+			window := registry.Window()
+
+			// 	then
+			assert.Equal(t, 5*time.Minute, window)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestHighFrequencySmallIntervalRule(t *testing.T) {
+	givenTransaction := domain.Transaction{
+		Money:     domain.Money{Amount: 25},
+		Merchant:  "ifood",
+		CreatedAt: time.Now().UTC(),
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when max transactions reached within window": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+				{Merchant: "uber-eats", Money: domain.Money{Amount: 15}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+				{Merchant: "mercado", Money: domain.Money{Amount: 20}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewHighFrequencySmallIntervalRule(2*time.Minute, 3, false)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrHighFrequencySmallInterval, "3 transactions within 2m0s", map[string]interface{}{
+				"transactions":     3,
+				"max-transactions": 3,
+				"window":           "2m0s",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should ignore transactions outside of window": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTransaction.CreatedAt.Add(-3 * time.Minute)},
+				{Merchant: "uber-eats", Money: domain.Money{Amount: 15}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+				{Merchant: "mercado", Money: domain.Money{Amount: 20}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewHighFrequencySmallIntervalRule(2*time.Minute, 3, false)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should count later transactions within window after transaction time": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+				{Merchant: "uber-eats", Money: domain.Money{Amount: 15}, CreatedAt: givenTransaction.CreatedAt.Add(1 * time.Minute)},
+				{Merchant: "mercado", Money: domain.Money{Amount: 20}, CreatedAt: givenTransaction.CreatedAt.Add(3 * time.Minute)},
+			}
+			rule := NewHighFrequencySmallIntervalRule(2*time.Minute, 2, false)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrHighFrequencySmallInterval}, violationErrors(errs))
+		},
+		"should count declined transactions within window when counting attempts": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			givenDeclines := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+				{Merchant: "casino", Money: domain.Money{Amount: 800}, CreatedAt: givenTransaction.CreatedAt.Add(-30 * time.Second)},
+				{Merchant: "casino", Money: domain.Money{Amount: 700}, CreatedAt: givenTransaction.CreatedAt.Add(-5 * time.Minute)},
+			}
+			rule := NewHighFrequencySmallIntervalRule(2*time.Minute, 3, true)
+
+			// 	when
+			errs := rule.EvaluateDeclines(domain.Account{}, givenTransaction, givenHistory, givenDeclines)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrHighFrequencySmallInterval}, violationErrors(errs))
+		},
+		"should ignore declined transactions when not counting attempts": func(t *testing.T) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+				{Merchant: "casino", Money: domain.Money{Amount: 800}, CreatedAt: givenTransaction.CreatedAt.Add(-30 * time.Second)},
+				{Merchant: "casino", Money: domain.Money{Amount: 700}, CreatedAt: givenTransaction.CreatedAt.Add(-10 * time.Second)},
+			}
+			rule := NewHighFrequencySmallIntervalRule(2*time.Minute, 3, false)
+
+			// 	when
+			errs := rule.EvaluateDeclines(domain.Account{}, givenTransaction, nil, givenDeclines)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should ignore later transactions outside of window": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTransaction.CreatedAt.Add(3 * time.Minute)},
+				{Merchant: "uber-eats", Money: domain.Money{Amount: 15}, CreatedAt: givenTransaction.CreatedAt.Add(24 * time.Hour)},
+			}
+			rule := NewHighFrequencySmallIntervalRule(2*time.Minute, 1, false)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestTooManyDeclinesRule(t *testing.T) {
+	givenTransaction := domain.Transaction{Merchant: "casino", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC()}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when declined transaction reaches max declines within window": func(t *testing.T) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt.Add(-5 * time.Minute)},
+				{Merchant: "casino", Money: domain.Money{Amount: 800}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewTooManyDeclinesRule(10*time.Minute, 3)
+
+			// 	when
+			errs := rule.EvaluateLock(domain.Account{}, givenTransaction, givenDeclines)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrTooManyDeclines, "3 declined transactions within 10m0s", map[string]interface{}{
+				"declines":     3,
+				"max-declines": 3,
+				"window":       "10m0s",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should not return error before declined transaction reaches max declines": func(t *testing.T) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewTooManyDeclinesRule(10*time.Minute, 3)
+
+			// 	when
+			errs := rule.EvaluateLock(domain.Account{}, givenTransaction, givenDeclines)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should ignore declines outside of window": func(t *testing.T) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt.Add(-time.Hour)},
+				{Merchant: "casino", Money: domain.Money{Amount: 800}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewTooManyDeclinesRule(10*time.Minute, 3)
+
+			// 	when
+			errs := rule.EvaluateLock(domain.Account{}, givenTransaction, givenDeclines)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should not decline transaction on its own": func(t *testing.T) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			registry := NewRuleRegistry(NewTooManyDeclinesRule(10*time.Minute, 1), InsufficientLimitRule{})
+
+			// 	when
+			errs := registry.Evaluate(domain.Account{ActiveCard: true, AvailableLimit: 100}, givenTransaction, nil, givenDeclines, nil)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should be evaluated by the registry over declined transaction": func(t *testing.T) {
+			// 	given
+			registry := NewRuleRegistry(ActiveCardRule{}, NewTooManyDeclinesRule(10*time.Minute, 1), InsufficientLimitRule{})
+
+			// 	when
+			errs := registry.EvaluateLocks(domain.Account{ActiveCard: true}, givenTransaction, nil)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrTooManyDeclines}, violationErrors(errs))
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestActiveCardRule(t *testing.T) {
+	givenTransaction := domain.Transaction{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when card is not active": func(t *testing.T) {
+			// 	when
+			errs := ActiveCardRule{}.Evaluate(domain.Account{ActiveCard: false, AvailableLimit: 100}, givenTransaction, nil)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrCardNotActive}, violationErrors(errs))
+		},
+		"should return error with reason when card is blocked": func(t *testing.T) {
+			// 	when
+			errs := ActiveCardRule{}.Evaluate(domain.Account{AvailableLimit: 100, BlockReason: "lost"}, givenTransaction, nil)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrCardBlocked, "account card is blocked", map[string]interface{}{
+				"reason": "lost",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when account is closed": func(t *testing.T) {
+			// 	when
+			errs := ActiveCardRule{}.Evaluate(domain.Account{AvailableLimit: 100, Closed: true}, givenTransaction, nil)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrAccountClosed}, violationErrors(errs))
+		},
+		"should return empty errors when card is active": func(t *testing.T) {
+			// 	when
+			errs := ActiveCardRule{}.Evaluate(domain.Account{ActiveCard: true, AvailableLimit: 100}, givenTransaction, nil)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestInsufficientLimitRule(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should detail requested and available amounts": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+
+			// 	when
+			errs := InsufficientLimitRule{}.Evaluate(domain.Account{ActiveCard: true, AvailableLimit: 10}, givenTransaction, nil)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrInsufficientLimit, "amount exceeds the available limit", map[string]interface{}{
+				"requested": 25,
+				"available": 10,
+			})}
+			assert.Equal(t, wantErrs, errs)
+			assert.ErrorIs(t, errs[0], domain.ErrInsufficientLimit)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestDoubleTransactionRule(t *testing.T) {
+	givenTransaction := domain.Transaction{
+		Money:     domain.Money{Amount: 25},
+		Merchant:  "ifood",
+		CreatedAt: time.Now().UTC(),
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when same amount and merchant within window": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewDoubleTransactionRule(2 * time.Minute)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrDoubleTransaction, "matches a transaction within 2m0s", map[string]interface{}{
+				"transaction": givenHistory[0],
+				"match":       []string{MatchAmount, MatchMerchant},
+				"window":      "2m0s",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should match transactions only by configured fields": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Minute)},
+			}
+			rule := NewDoubleTransactionRule(2*time.Minute, MatchMerchant)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrDoubleTransaction}, violationErrors(errs))
+		},
+		"should ignore same transaction outside of window": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenTransaction.CreatedAt.Add(-3 * time.Minute)},
+			}
+			rule := NewDoubleTransactionRule(2 * time.Minute)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestTransactionTimeRule(t *testing.T) {
+	givenNow := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	rule := NewTransactionTimeRule(5*time.Minute, 24*time.Hour, fakeClock{now: givenNow})
+
+	testCases := map[string]func(*testing.T){
+		"should return error when transaction time is too far in the future": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenNow.Add(6 * time.Minute)}
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, nil)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrTransactionTimeInvalid, "transaction time is too far in the future", map[string]interface{}{
+				"time":       "2019-02-13T10:06:00Z",
+				"now":        "2019-02-13T10:00:00Z",
+				"clock-skew": "5m0s",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when transaction time is too far in the past": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenNow.Add(-25 * time.Hour)}
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, nil)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrTransactionTimeInvalid, "transaction time is too far in the past", map[string]interface{}{
+				"time":    "2019-02-12T09:00:00Z",
+				"now":     "2019-02-13T10:00:00Z",
+				"max-age": "24h0m0s",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return no error when transaction time is within tolerance": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenNow.Add(4 * time.Minute)}
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, nil)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should not check unset bound": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenNow.Add(-365 * 24 * time.Hour)}
+			rule := NewTransactionTimeRule(5*time.Minute, 0, fakeClock{now: givenNow})
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, nil)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
+
+func TestNewRules(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should build rules from given config": func(t *testing.T) {
+			// 	given
+			givenConfig := RulesConfig{
+				HighFrequencyWindow:          time.Minute,
+				HighFrequencyMaxTransactions: 5,
+				DoubleTransactionWindow:      30 * time.Second,
+				DoubleTransactionMatch:       []string{MatchMerchant},
+			}
+
+			// 	when
+			registry, err := NewRules(givenConfig)
+
+			// 	then
+			wantRules := []Rule{
+				ActiveCardRule{},
+				InsufficientLimitRule{},
+				NewHighFrequencySmallIntervalRule(time.Minute, 5, false),
+				NewDoubleTransactionRule(30*time.Second, MatchMerchant),
+				NewSpendLimitRule(time.UTC),
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, wantRules, registry.Rules())
+		},
+		"should register spending limit rules in key order": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.MerchantLimits = map[string]SpendingLimit{
+				"casino":    {PerTransaction: 200},
+				"bet-house": {Daily: 500},
+			}
+			givenConfig.MCCLimits = map[string]SpendingLimit{"7995": {Monthly: 2000}}
+
+			// 	when
+			registry, err := NewRules(givenConfig)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []Rule{
+				NewMerchantLimitRule("bet-house", SpendingLimit{Daily: 500}, time.UTC),
+				NewMerchantLimitRule("casino", SpendingLimit{PerTransaction: 200}, time.UTC),
+				NewMCCLimitRule("7995", SpendingLimit{Monthly: 2000}, time.UTC),
+			}, registry.Rules()[5:])
+		},
+		"should register transaction time rule first when a tolerance is given": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.ClockSkew = 5 * time.Minute
+
+			// 	when
+			registry, err := NewRules(givenConfig)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, "transaction-time", registry.Rules()[0].Name())
+			assert.Len(t, registry.Rules(), 6)
+		},
+		"should register too many declines rule after active card rule when max declines is given": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.TooManyDeclinesMax = 5
+
+			// 	when
+			registry, err := NewRules(givenConfig)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, NewTooManyDeclinesRule(10*time.Minute, 5), registry.Rules()[1])
+			assert.Equal(t, 10*time.Minute, registry.Window())
+		},
+		"should return error when too many declines max is negative": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.TooManyDeclinesMax = -1
+
+			// 	when
+			_, err := NewRules(givenConfig)
+
+			// 	then
+			assert.EqualError(t, err, "too many declines max must not be negative")
+		},
+		"should return error when transaction time tolerance is negative": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.MaxTransactionAge = -time.Hour
+
+			// 	when
+			_, err := NewRules(givenConfig)
+
+			// 	then
+			assert.EqualError(t, err, "transaction time tolerances must not be negative")
+		},
+		"should return error when spending limit is negative": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.MCCLimits = map[string]SpendingLimit{"7995": {Daily: -1}}
+
+			// 	when
+			_, err := NewRules(givenConfig)
+
+			// 	then
+			assert.EqualError(t, err, "spending limits must not be negative")
+		},
+		"should return error when window is not positive": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.DoubleTransactionWindow = 0
+
+			// 	when
+			_, err := NewRules(givenConfig)
+
+			// 	then
+			assert.EqualError(t, err, "rule windows must be positive")
+		},
+		"should return error when match field is unknown": func(t *testing.T) {
+			// 	given
+			givenConfig := DefaultRulesConfig()
+			givenConfig.DoubleTransactionMatch = []string{"mcc"}
+
+			// 	when
+			_, err := NewRules(givenConfig)
+
+			// 	then
+			assert.EqualError(t, err, `unknown double transaction match field "mcc"`)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+// violationErrors returns the violation errors wrapped by the given violations, dropping their details.
+func violationErrors(errs []error) []error {
+	unwrapped := []error{}
+	for _, err := range errs {
+		if violation, ok := err.(domain.Violation); ok {
+			err = violation.Unwrap()
+		}
+		unwrapped = append(unwrapped, err)
+	}
+	return unwrapped
+}
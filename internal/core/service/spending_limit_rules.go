@@ -0,0 +1,285 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+const (
+	PeriodTransaction = "transaction"
+	PeriodDay         = "day"
+	PeriodMonth       = "month"
+)
+
+type (
+	// SpendingLimit caps the amount spent per transaction, per calendar day and per calendar month, a zero cap is unset.
+	SpendingLimit struct {
+		PerTransaction int
+		Daily          int
+		Monthly        int
+	}
+
+	// MerchantLimitRule caps the spending of the account at a merchant.
+	MerchantLimitRule struct {
+		merchant string
+		limit    SpendingLimit
+		location *time.Location
+	}
+
+	// MCCLimitRule caps the spending of the account at the merchants of a category.
+	MCCLimitRule struct {
+		mcc      string
+		limit    SpendingLimit
+		location *time.Location
+	}
+
+	// SpendLimitRule caps the spending of the account per calendar day and month at its daily and monthly limits.
+	SpendLimitRule struct {
+		location *time.Location
+	}
+)
+
+func NewMerchantLimitRule(merchant string, limit SpendingLimit, location *time.Location) MerchantLimitRule {
+	return MerchantLimitRule{
+		merchant: merchant,
+		limit:    limit,
+		location: location,
+	}
+}
+
+func (MerchantLimitRule) Name() string {
+	return "merchant-limit"
+}
+
+func (r MerchantLimitRule) Window() time.Duration {
+	return r.limit.window()
+}
+
+func (r MerchantLimitRule) Evaluate(_ domain.Account, transaction domain.Transaction, history []domain.Transaction) []error {
+	if transaction.Merchant != r.merchant {
+		return nil
+	}
+
+	pastTransactions := []domain.Transaction{}
+	for _, pastTransaction := range history {
+		if pastTransaction.Merchant == r.merchant {
+			pastTransactions = append(pastTransactions, pastTransaction)
+		}
+	}
+	return r.violations(transaction, historySpent(pastTransactions))
+}
+
+// EvaluateSpending checks the transaction against the caps over the amounts spent at the merchant, held ones included,
+// so holds and the captures they settle into count against the caps as well.
+func (r MerchantLimitRule) EvaluateSpending(_ domain.Account, transaction domain.Transaction, spending domain.Spending) []error {
+	if transaction.Merchant != r.merchant {
+		return nil
+	}
+	return r.violations(transaction, spending.AtMerchant(r.merchant).SpentBetween)
+}
+
+// violations returns the violation of the first cap the transaction exceeds, given the amounts spent at the merchant.
+func (r MerchantLimitRule) violations(transaction domain.Transaction, spentBetween func(time.Time, time.Time) int) []error {
+	period, limit, spent, exceeded := r.limit.exceeded(transaction, spentBetween, r.location)
+	if !exceeded {
+		return nil
+	}
+	return []error{domain.NewViolation(
+		domain.ErrMerchantLimitExceeded,
+		fmt.Sprintf("exceeds the limit of %d per %s at merchant %s", limit, period, r.merchant),
+		map[string]interface{}{
+			"merchant":  r.merchant,
+			"period":    period,
+			"limit":     limit,
+			"spent":     spent,
+			"requested": transaction.Amount,
+		},
+	)}
+}
+
+func NewMCCLimitRule(mcc string, limit SpendingLimit, location *time.Location) MCCLimitRule {
+	return MCCLimitRule{
+		mcc:      mcc,
+		limit:    limit,
+		location: location,
+	}
+}
+
+func (MCCLimitRule) Name() string {
+	return "mcc-limit"
+}
+
+func (r MCCLimitRule) Window() time.Duration {
+	return r.limit.window()
+}
+
+func (r MCCLimitRule) Evaluate(_ domain.Account, transaction domain.Transaction, history []domain.Transaction) []error {
+	if transaction.MCC != r.mcc {
+		return nil
+	}
+
+	pastTransactions := []domain.Transaction{}
+	for _, pastTransaction := range history {
+		if pastTransaction.MCC == r.mcc {
+			pastTransactions = append(pastTransactions, pastTransaction)
+		}
+	}
+	return r.violations(transaction, historySpent(pastTransactions))
+}
+
+// EvaluateSpending checks the transaction against the caps over the amounts spent at the merchants of the category,
+// held ones included, so holds and the captures they settle into count against the caps as well.
+func (r MCCLimitRule) EvaluateSpending(_ domain.Account, transaction domain.Transaction, spending domain.Spending) []error {
+	if transaction.MCC != r.mcc {
+		return nil
+	}
+	return r.violations(transaction, spending.InCategory(r.mcc).SpentBetween)
+}
+
+// violations returns the violation of the first cap the transaction exceeds, given the amounts spent in the category.
+func (r MCCLimitRule) violations(transaction domain.Transaction, spentBetween func(time.Time, time.Time) int) []error {
+	period, limit, spent, exceeded := r.limit.exceeded(transaction, spentBetween, r.location)
+	if !exceeded {
+		return nil
+	}
+	return []error{domain.NewViolation(
+		domain.ErrMCCLimitExceeded,
+		fmt.Sprintf("exceeds the limit of %d per %s at merchant category %s", limit, period, r.mcc),
+		map[string]interface{}{
+			"mcc":       r.mcc,
+			"period":    period,
+			"limit":     limit,
+			"spent":     spent,
+			"requested": transaction.Amount,
+		},
+	)}
+}
+
+// window returns how far back the transactions counted against the limit go, a calendar month being at most 31 days
+// and a calendar day at most 25 hours long, with daylight saving time.
+func (l SpendingLimit) window() time.Duration {
+	switch {
+	case l.Monthly > 0:
+		return 31*24*time.Hour + time.Hour
+	case l.Daily > 0:
+		return 25 * time.Hour
+	}
+	return 0
+}
+
+// exceeded returns the first period whose cap the transaction exceeds, along with the cap and the amount already
+// spent in the period, as spentBetween sums it.
+func (l SpendingLimit) exceeded(
+	transaction domain.Transaction,
+	spentBetween func(time.Time, time.Time) int,
+	location *time.Location,
+) (string, int, int, bool) {
+	if l.PerTransaction > 0 && transaction.Amount > l.PerTransaction {
+		return PeriodTransaction, l.PerTransaction, 0, true
+	}
+
+	dayStart, dayEnd := calendarDay(transaction.CreatedAt, location)
+	monthStart, monthEnd := calendarMonth(transaction.CreatedAt, location)
+	periods := []struct {
+		name       string
+		limit      int
+		start, end time.Time
+	}{
+		{PeriodDay, l.Daily, dayStart, dayEnd},
+		{PeriodMonth, l.Monthly, monthStart, monthEnd},
+	}
+	for _, period := range periods {
+		if period.limit <= 0 {
+			continue
+		}
+		spent := spentBetween(period.start, period.end)
+		if spent+transaction.Amount > period.limit {
+			return period.name, period.limit, spent, true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// historySpent returns the sum of the amounts of the transactions made from start until end, leaving out reversed ones.
+func historySpent(history []domain.Transaction) func(time.Time, time.Time) int {
+	return func(start time.Time, end time.Time) int {
+		spent := 0
+		for _, pastTransaction := range history {
+			if !pastTransaction.Reversed && !pastTransaction.CreatedAt.Before(start) && pastTransaction.CreatedAt.Before(end) {
+				spent += pastTransaction.Amount
+			}
+		}
+		return spent
+	}
+}
+
+func NewSpendLimitRule(location *time.Location) SpendLimitRule {
+	return SpendLimitRule{location: location}
+}
+
+func (SpendLimitRule) Name() string {
+	return "spend-limit"
+}
+
+func (SpendLimitRule) Window() time.Duration {
+	return 0
+}
+
+// Evaluate has no spending to sum the calendar periods over, so it never finds a violation.
+func (SpendLimitRule) Evaluate(_ domain.Account, _ domain.Transaction, _ []domain.Transaction) []error {
+	return nil
+}
+
+// EvaluateSpending checks the amount spent in the calendar day and month of the transaction, along with the
+// transaction amount, against the daily and monthly limits of the account, an unset limit is not checked.
+func (r SpendLimitRule) EvaluateSpending(account domain.Account, transaction domain.Transaction, spending domain.Spending) []error {
+	dayStart, dayEnd := calendarDay(transaction.CreatedAt, r.location)
+	monthStart, monthEnd := calendarMonth(transaction.CreatedAt, r.location)
+	periods := []struct {
+		err        error
+		name       string
+		limit      int
+		start, end time.Time
+	}{
+		{domain.ErrDailyLimitExceeded, PeriodDay, account.DailyLimit, dayStart, dayEnd},
+		{domain.ErrMonthlyLimitExceeded, PeriodMonth, account.MonthlyLimit, monthStart, monthEnd},
+	}
+
+	violations := []error{}
+	for _, period := range periods {
+		if period.limit <= 0 {
+			continue
+		}
+		spent := spending.SpentBetween(period.start, period.end)
+		if spent+transaction.Amount > period.limit {
+			violations = append(violations, domain.NewViolation(
+				period.err,
+				fmt.Sprintf("exceeds the limit of %d per %s", period.limit, period.name),
+				map[string]interface{}{
+					"limit":     period.limit,
+					"spent":     spent,
+					"requested": transaction.Amount,
+					"from":      period.start.Format(time.RFC3339),
+					"to":        period.end.Format(time.RFC3339),
+				},
+			))
+		}
+	}
+	return violations
+}
+
+// calendarDay returns the start and end of the calendar day of the given time at the location.
+func calendarDay(at time.Time, location *time.Location) (time.Time, time.Time) {
+	at = at.In(location)
+	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, location)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// calendarMonth returns the start and end of the calendar month of the given time at the location.
+func calendarMonth(at time.Time, location *time.Location) (time.Time, time.Time) {
+	at = at.In(location)
+	start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, location)
+	return start, start.AddDate(0, 1, 0)
+}
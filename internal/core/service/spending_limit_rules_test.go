@@ -0,0 +1,279 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestMerchantLimitRule(t *testing.T) {
+	givenTransaction := domain.Transaction{
+		Money:     domain.Money{Amount: 50},
+		Merchant:  "casino",
+		CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when amount exceeds per transaction limit": func(t *testing.T) {
+			// 	given
+			rule := NewMerchantLimitRule("casino", SpendingLimit{PerTransaction: 40}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, nil)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrMerchantLimitExceeded, "exceeds the limit of 40 per transaction at merchant casino", map[string]interface{}{
+				"merchant":  "casino",
+				"period":    PeriodTransaction,
+				"limit":     40,
+				"spent":     0,
+				"requested": 50,
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when day spending exceeds daily limit": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+				{Merchant: "ifood", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+			}
+			rule := NewMerchantLimitRule("casino", SpendingLimit{Daily: 70, Monthly: 1000}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrMerchantLimitExceeded, "exceeds the limit of 70 per day at merchant casino", map[string]interface{}{
+				"merchant":  "casino",
+				"period":    PeriodDay,
+				"limit":     70,
+				"spent":     30,
+				"requested": 50,
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should not count transactions of later days against daily limit": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(24 * time.Hour)},
+			}
+			rule := NewMerchantLimitRule("casino", SpendingLimit{Daily: 70}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should return error when month spending exceeds monthly limit": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt.Add(-10 * 24 * time.Hour)},
+			}
+			rule := NewMerchantLimitRule("casino", SpendingLimit{Daily: 70, Monthly: 100}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrMerchantLimitExceeded}, violationErrors(errs))
+		},
+		"should ignore transactions before calendar period and reversed ones": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "casino", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(-11 * time.Hour)},
+				{Merchant: "casino", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour), Reversed: true},
+			}
+			rule := NewMerchantLimitRule("casino", SpendingLimit{Daily: 70}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should return error when day spending at merchant exceeds daily limit": func(t *testing.T) {
+			// 	given
+			givenSpending := historySpending{
+				{Merchant: "casino", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+				{Merchant: "ifood", Money: domain.Money{Amount: 30}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+			}
+			rule := NewMerchantLimitRule("casino", SpendingLimit{Daily: 70}, time.UTC)
+
+			// 	when
+			errs := rule.EvaluateSpending(domain.Account{}, givenTransaction, givenSpending)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrMerchantLimitExceeded}, violationErrors(errs))
+		},
+		"should ignore transactions at other merchants": func(t *testing.T) {
+			// 	given
+			rule := NewMerchantLimitRule("bet-house", SpendingLimit{PerTransaction: 10}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, nil)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestMCCLimitRule(t *testing.T) {
+	givenTransaction := domain.Transaction{
+		Money:     domain.Money{Amount: 50},
+		Merchant:  "casino",
+		MCC:       "7995",
+		CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when category spending exceeds daily limit": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "bet-house", MCC: "7995", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+			}
+			rule := NewMCCLimitRule("7995", SpendingLimit{Daily: 100}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrMCCLimitExceeded, "exceeds the limit of 100 per day at merchant category 7995", map[string]interface{}{
+				"mcc":       "7995",
+				"period":    PeriodDay,
+				"limit":     100,
+				"spent":     60,
+				"requested": 50,
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should ignore transactions of other categories": func(t *testing.T) {
+			// 	given
+			givenHistory := []domain.Transaction{
+				{Merchant: "ifood", MCC: "5812", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+			}
+			rule := NewMCCLimitRule("7995", SpendingLimit{Daily: 100}, time.UTC)
+
+			// 	when
+			errs := rule.Evaluate(domain.Account{}, givenTransaction, givenHistory)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+		"should return error when day spending in category exceeds daily limit": func(t *testing.T) {
+			// 	given
+			givenSpending := historySpending{
+				{Merchant: "bet-house", MCC: "7995", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+				{Merchant: "ifood", MCC: "5812", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt.Add(-1 * time.Hour)},
+			}
+			rule := NewMCCLimitRule("7995", SpendingLimit{Daily: 100}, time.UTC)
+
+			// 	when
+			errs := rule.EvaluateSpending(domain.Account{}, givenTransaction, givenSpending)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrMCCLimitExceeded}, violationErrors(errs))
+		},
+		"should return window covering a calendar month": func(t *testing.T) {
+			// 	when
+			window := NewMCCLimitRule("7995", SpendingLimit{Daily: 100, Monthly: 500}, time.UTC).Window()
+
+			// 	then
+			assert.Equal(t, 31*24*time.Hour+time.Hour, window)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestSpendLimitRule(t *testing.T) {
+	givenTransaction := domain.Transaction{
+		Money:     domain.Money{Amount: 50},
+		Merchant:  "ifood",
+		CreatedAt: time.Date(2019, 02, 13, 1, 0, 0, 0, time.UTC),
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should return error when day spending exceeds daily limit": func(t *testing.T) {
+			// 	given
+			givenAccount := domain.Account{ActiveCard: true, AvailableLimit: 1000, DailyLimit: 100}
+			givenSpending := historySpending{
+				{Money: domain.Money{Amount: 60}, CreatedAt: time.Date(2019, 02, 13, 0, 30, 0, 0, time.UTC)},
+				{Money: domain.Money{Amount: 60}, CreatedAt: time.Date(2019, 02, 12, 23, 30, 0, 0, time.UTC)},
+			}
+
+			// 	when
+			errs := NewSpendLimitRule(time.UTC).EvaluateSpending(givenAccount, givenTransaction, givenSpending)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrDailyLimitExceeded, "exceeds the limit of 100 per day", map[string]interface{}{
+				"limit":     100,
+				"spent":     60,
+				"requested": 50,
+				"from":      "2019-02-13T00:00:00Z",
+				"to":        "2019-02-14T00:00:00Z",
+			})}
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should sum calendar day of configured timezone": func(t *testing.T) {
+			// 	given
+			location := time.FixedZone("UTC-3", -3*60*60)
+			givenAccount := domain.Account{ActiveCard: true, AvailableLimit: 1000, DailyLimit: 100}
+			givenSpending := historySpending{
+				{Money: domain.Money{Amount: 60}, CreatedAt: time.Date(2019, 02, 13, 0, 30, 0, 0, time.UTC)},
+				{Money: domain.Money{Amount: 60}, CreatedAt: time.Date(2019, 02, 12, 23, 30, 0, 0, time.UTC)},
+			}
+
+			// 	when
+			errs := NewSpendLimitRule(location).EvaluateSpending(givenAccount, givenTransaction, givenSpending)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrDailyLimitExceeded}, violationErrors(errs))
+			assert.Equal(t, 120, errs[0].(domain.Violation).Metadata["spent"])
+		},
+		"should return both errors when day and month spending exceed limits": func(t *testing.T) {
+			// 	given
+			givenAccount := domain.Account{ActiveCard: true, AvailableLimit: 1000, DailyLimit: 100, MonthlyLimit: 100}
+			givenSpending := historySpending{
+				{Money: domain.Money{Amount: 60}, CreatedAt: time.Date(2019, 02, 13, 0, 30, 0, 0, time.UTC)},
+			}
+
+			// 	when
+			errs := NewSpendLimitRule(time.UTC).EvaluateSpending(givenAccount, givenTransaction, givenSpending)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrDailyLimitExceeded, domain.ErrMonthlyLimitExceeded}, violationErrors(errs))
+		},
+		"should return empty errors when account has no spend limits": func(t *testing.T) {
+			// 	given
+			givenSpending := historySpending{
+				{Money: domain.Money{Amount: 6000}, CreatedAt: time.Date(2019, 02, 13, 0, 30, 0, 0, time.UTC)},
+			}
+
+			// 	when
+			errs := NewSpendLimitRule(time.UTC).EvaluateSpending(domain.Account{ActiveCard: true, AvailableLimit: 1000}, givenTransaction, givenSpending)
+
+			// 	then
+			assert.Empty(t, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
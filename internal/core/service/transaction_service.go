@@ -1,71 +1,193 @@
 package service
 
 import (
+	"errors"
 	"time"
 
 	"github.com/unknown/authorizer/internal/core/domain"
 )
 
 type (
-	AccountServicer interface {
-		GetAccount() (domain.Account, error)
-		SetAccountLimit(newAvailableLimit int) domain.Account
-	}
-
 	TransactionRepository interface {
-		SaveTransaction(domain.Transaction)
-		FindTransactionsAfter(time.Time) []domain.Transaction
+		FindAccount(accountID string) (domain.Account, error)
+		FindTransactionsAfter(accountID string, after time.Time) []domain.Transaction
+		// CommitTransaction atomically authorizes the transaction over its account, the account transactions and
+		// declined transactions after the given time and the account spending, recording it as authorized when there
+		// are no violations and as rejected otherwise, along with the card change of the authorization. A transaction
+		// with the id of a hold or transaction the account already has fails with domain.ErrTransactionExists, and one
+		// with the idempotency key of a recorded transaction it differs from fails with domain.ErrKeyReused.
+		CommitTransaction(
+			transaction domain.Transaction,
+			after time.Time,
+			authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+		) (domain.Account, []error, error)
+		// SimulateTransaction atomically authorizes the transaction like CommitTransaction but records nothing,
+		// returning the account as it would be if the transaction were committed.
+		SimulateTransaction(
+			transaction domain.Transaction,
+			after time.Time,
+			authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+		) (domain.Account, []error, error)
+		// CommitReversal atomically validates the reversal over its account and the authorized transaction it
+		// references, recording it when there are no violations.
+		CommitReversal(
+			reversal domain.Reversal,
+			validate func(account domain.Account, transaction domain.Transaction, found bool) []error,
+		) (domain.Account, []error, error)
+		// FindDecisions returns the decisions on the transactions of the account matching the filter, authorized and
+		// rejected alike, in the order they were made.
+		FindDecisions(accountID string, filter domain.DecisionFilter) ([]domain.Decision, error)
 	}
 
 	TransactionService struct {
-		repository     TransactionRepository
-		accountService AccountServicer
+		repository TransactionRepository
+		rules      RuleRegistry
+		clock      Clock
+		timeBasis  string
+		rates      ExchangeRates
 	}
 )
 
-func NewTransactionService(repository TransactionRepository, accountManager AccountServicer) TransactionService {
+// NewTransactionService returns the service checking the rule windows at the event or processing time, as told by the
+// time basis, the processing time being told by the clock. Transactions in another currency than the one of their
+// account are converted at the given rates.
+func NewTransactionService(
+	repository TransactionRepository,
+	rules RuleRegistry,
+	clock Clock,
+	timeBasis string,
+	rates ExchangeRates,
+) TransactionService {
 	return TransactionService{
-		repository:     repository,
-		accountService: accountManager,
+		repository: repository,
+		rules:      rules,
+		clock:      clock,
+		timeBasis:  timeBasis,
+		rates:      rates,
 	}
 }
 
+// AuthorizeTransaction checks the transaction against the rules, over the account transactions from the widest rule
+// window before it on, so transactions arriving out of order are also checked against the later ones. On processing
+// time the transaction is made at the current time, whatever time it was sent with. A declined transaction violating
+// the lock rules blocks the card of its account in the same commit. A transaction with the id of another one of the
+// account is violated by domain.ErrDuplicateTransactionID without being checked, and one with the idempotency key of
+// a different transaction by domain.ErrIdempotencyKeyReused.
 func (s TransactionService) AuthorizeTransaction(transaction domain.Transaction) (domain.Account, []error) {
-	account, err := s.accountService.GetAccount()
-	if err != nil {
-		return domain.Account{}, []error{err}
+	return s.authorize(transaction, s.repository.CommitTransaction)
+}
+
+// SimulateTransaction checks the transaction like AuthorizeTransaction, returning the account as it would be if the
+// transaction were authorized, but leaves the repository untouched.
+func (s TransactionService) SimulateTransaction(transaction domain.Transaction) (domain.Account, []error) {
+	return s.authorize(transaction, s.repository.SimulateTransaction)
+}
+
+// authorize checks the transaction against the rules through the given commit, which records its outcome or not. The
+// transaction is converted to the currency of its account along with the checks, over the account the commit holds.
+func (s TransactionService) authorize(
+	transaction domain.Transaction,
+	commit func(domain.Transaction, time.Time, func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization) (domain.Account, []error, error),
+) (domain.Account, []error) {
+	if s.timeBasis == TimeBasisProcessing {
+		transaction.CreatedAt = s.clock.Now().UTC()
 	}
+	windowStart := transaction.CreatedAt.UTC().Add(-s.rules.Window())
 
-	if !account.ActiveCard {
-		return account, []error{domain.ErrCardNotActive}
+	account, errs, err := commit(transaction, windowStart, func(account domain.Account, pastTransactions []domain.Transaction, declines []domain.Transaction, spending domain.Spending) domain.Authorization {
+		converted, err := s.convert(account, transaction)
+		if err != nil {
+			return s.lock(account, domain.Authorization{Transaction: transaction, Violations: []error{err}}, declines)
+		}
+		return s.lock(account, domain.Authorization{
+			Transaction: converted,
+			Violations:  s.rules.Evaluate(account, converted, pastTransactions, declines, spending),
+		}, declines)
+	})
+	if errors.Is(err, domain.ErrTransactionExists) {
+		return account, []error{domain.ErrDuplicateTransactionID}
+	}
+	if errors.Is(err, domain.ErrKeyReused) {
+		return account, []error{domain.ErrIdempotencyKeyReused}
+	}
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
 	}
 
-	errors := []error{}
-	if account.AvailableLimit < transaction.Amount {
-		errors = append(errors, domain.ErrInsufficientLimit)
+	return account, errs
+}
+
+// lock adds the violations of the lock rules to the declined authorization, blocking the card of its account along with
+// the decline when there are any, the first violation as block reason, so it takes an unblock to use the card again. A
+// card the lifecycle can not block is left as it is.
+func (s TransactionService) lock(
+	account domain.Account,
+	authorization domain.Authorization,
+	declines []domain.Transaction,
+) domain.Authorization {
+	if len(authorization.Violations) == 0 {
+		return authorization
+	}
+	if _, ok := domain.CardTransition(account.CardStatus(), domain.CardBlock); !ok {
+		return authorization
 	}
 
-	twoMinutesAgo := transaction.CreatedAt.UTC().Add(-2 * time.Minute)
-	pastTransactions := s.repository.FindTransactionsAfter(twoMinutesAgo)
-	if len(pastTransactions) >= 3 {
-		errors = append(errors, domain.ErrHighFrequencySmallInterval)
+	errs := s.rules.EvaluateLocks(account, authorization.Transaction, declines)
+	if len(errs) == 0 {
+		return authorization
 	}
+	authorization.Violations = append(authorization.Violations, errs...)
+	authorization.CardChange = &domain.CardChange{
+		AccountID: authorization.Transaction.AccountID,
+		Action:    domain.CardBlock,
+		Reason:    errs[0].Error(),
+		CreatedAt: authorization.Transaction.CreatedAt,
+	}
+	return authorization
+}
 
-	for _, pastTransaction := range pastTransactions {
-		if pastTransaction.Amount == transaction.Amount && pastTransaction.Merchant == transaction.Merchant {
-			errors = append(errors, domain.ErrDoubleTransaction)
-			break
-		}
+// convert returns the transaction in the currency of its account, converting it when made in another one.
+func (s TransactionService) convert(account domain.Account, transaction domain.Transaction) (domain.Transaction, error) {
+	money, original, err := s.rates.convertToAccount(account, transaction.Money)
+	if err != nil {
+		return transaction, err
 	}
+	transaction.Money, transaction.Original = money, original
+	return transaction, nil
+}
 
-	if len(errors) >= 1 {
-		return account, errors
+// ReverseTransaction undoes a previously authorized transaction, restoring its amount to the account limit. On
+// processing time the reversal is made at the current time, so it can not expire the holds of the account early.
+func (s TransactionService) ReverseTransaction(reversal domain.Reversal) (domain.Account, []error) {
+	if s.timeBasis == TimeBasisProcessing {
+		reversal.CreatedAt = s.clock.Now().UTC()
+	}
+	account, errs, err := s.repository.CommitReversal(reversal, func(_ domain.Account, transaction domain.Transaction, found bool) []error {
+		if !found {
+			return []error{domain.ErrTransactionNotFound}
+		}
+		if transaction.Reversed {
+			return []error{domain.ErrTransactionAlreadyReversed}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Account{}, []error{repositoryError(err)}
 	}
 
-	s.repository.SaveTransaction(transaction)
+	return account, errs
+}
 
-	limit := account.AvailableLimit - transaction.Amount
-	updatedAccount := s.accountService.SetAccountLimit(limit)
+func (s TransactionService) GetTransactions(accountID string) []domain.Transaction {
+	return s.repository.FindTransactionsAfter(accountID, time.Time{})
+}
 
-	return updatedAccount, []error{}
+// GetDecisions returns the decisions on the transactions of the account matching the filter, so rejected attempts can
+// be reviewed along with the authorized ones.
+func (s TransactionService) GetDecisions(accountID string, filter domain.DecisionFilter) ([]domain.Decision, error) {
+	decisions, err := s.repository.FindDecisions(accountID, filter)
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+	return decisions, nil
 }
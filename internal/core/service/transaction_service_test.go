@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -10,117 +11,265 @@ import (
 )
 
 func TestAuthorizeTransaction(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
 	givenInactiveAccount := domain.Account{
+		ID:             "1",
 		ActiveCard:     false,
 		AvailableLimit: 100,
 	}
 	givenActiveAccount := domain.Account{
+		ID:             "1",
 		ActiveCard:     true,
 		AvailableLimit: 100,
 	}
 	givenTransaction := domain.Transaction{
-		Amount:    25,
+		AccountID: "1",
+		Money:     domain.Money{Amount: 25},
 		Merchant:  "ifood",
-		CreatedAt: time.Now().UTC(),
+		CreatedAt: givenClock.now,
 	}
 	givenTime := givenTransaction.CreatedAt.Add(-2 * time.Minute)
 
-	testCases := map[string]func(*testing.T, *accountServicerMock, *transactionRepositoryMock){
-		"should return error when fail to get get account": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+	testCases := map[string]func(*testing.T, *transactionRepositoryMock){
+		"should return error when fail to get get account": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
-			accountServicerMock.On("GetAccount").Return(domain.Account{}, domain.ErrAccountNotInitialized)
+			transactionRepositoryMock.On("CommitTransaction", domain.Transaction{AccountID: "1"}, mock.AnythingOfType("Time")).Return(domain.Account{}, nil, domain.ErrAccountNotFound)
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
-			account, errs := transactionService.AuthorizeTransaction(domain.Transaction{})
+			account, errs := transactionService.AuthorizeTransaction(domain.Transaction{AccountID: "1"})
 
 			// 	then
 			assert.Empty(t, account)
 			assert.ElementsMatch(t, errs, []error{domain.ErrAccountNotInitialized})
 		},
-		"should return error when account card is not active": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+		"should return internal error when repository fails to commit": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
-			accountServicerMock.On("GetAccount").Return(givenInactiveAccount, nil)
+			transactionRepositoryMock.On("CommitTransaction", domain.Transaction{AccountID: "1"}, mock.AnythingOfType("Time")).Return(domain.Account{}, nil, errors.New("disk full"))
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
-			account, errs := transactionService.AuthorizeTransaction(domain.Transaction{})
+			account, errs := transactionService.AuthorizeTransaction(domain.Transaction{AccountID: "1"})
+
+			// 	then
+			assert.Empty(t, account)
+			assert.Len(t, errs, 1)
+			assert.ErrorIs(t, errs[0], domain.ErrInternal)
+		},
+		"should return violation when idempotency key was recorded with another transaction": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenKeyedTransaction := givenTransaction
+			givenKeyedTransaction.IdempotencyKey = "k1"
+			transactionRepositoryMock.On("CommitTransaction", givenKeyedTransaction, givenTime).Return(givenActiveAccount, nil, domain.ErrKeyReused)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenKeyedTransaction)
+
+			// 	then
+			assert.Equal(t, givenActiveAccount, account)
+			assert.Equal(t, []error{domain.ErrIdempotencyKeyReused}, errs)
+			assert.Empty(t, transactionRepositoryMock.recorded)
+		},
+		"should return violation when account already has transaction with id": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenIdentifiedTransaction := givenTransaction
+			givenIdentifiedTransaction.ID = "t1"
+			transactionRepositoryMock.On("CommitTransaction", givenIdentifiedTransaction, givenTime).Return(givenActiveAccount, nil, domain.ErrTransactionExists)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenIdentifiedTransaction)
+
+			// 	then
+			assert.Equal(t, givenActiveAccount, account)
+			assert.Equal(t, []error{domain.ErrDuplicateTransactionID}, errs)
+			assert.Empty(t, transactionRepositoryMock.recorded)
+		},
+		"should return error when account card is not active": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("CommitTransaction", domain.Transaction{AccountID: "1"}, mock.AnythingOfType("Time")).Return(givenInactiveAccount, []domain.Transaction{}, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(domain.Transaction{AccountID: "1"})
 
 			// 	then
 			assert.Equal(t, givenInactiveAccount, account)
-			assert.ElementsMatch(t, errs, []error{domain.ErrCardNotActive})
+			assert.ElementsMatch(t, violationErrors(errs), []error{domain.ErrCardNotActive})
 		},
-		"should return error when account has insufficient limit": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+		"should return error when account has insufficient limit": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
-			accountServicerMock.On("GetAccount").Return(givenActiveAccount, nil)
-			transactionRepositoryMock.On("FindTransactionsAfter", mock.AnythingOfType("Time")).Return([]domain.Transaction{})
+			givenTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 101}}
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, mock.AnythingOfType("Time")).Return(givenActiveAccount, []domain.Transaction{}, nil)
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
-			account, errs := transactionService.AuthorizeTransaction(domain.Transaction{Amount: 101})
+			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
 
 			// 	then
 			assert.Equal(t, givenActiveAccount, account)
-			assert.ElementsMatch(t, errs, []error{domain.ErrInsufficientLimit})
+			assert.ElementsMatch(t, violationErrors(errs), []error{domain.ErrInsufficientLimit})
+		},
+		"should lock card along with the decline reaching max declines": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 101}, Merchant: "casino", CreatedAt: givenClock.now}
+			givenDeclines := []domain.Transaction{
+				{AccountID: "1", Money: domain.Money{Amount: 900}, Merchant: "casino", CreatedAt: givenClock.now.Add(-2 * time.Minute)},
+				{AccountID: "1", Money: domain.Money{Amount: 800}, Merchant: "casino", CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+			}
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, mock.AnythingOfType("Time")).Return(givenActiveAccount, []domain.Transaction{}, nil, givenDeclines)
+
+			rulesConfig := DefaultRulesConfig()
+			rulesConfig.TooManyDeclinesMax = 3
+			rules, err := NewRules(rulesConfig)
+			assert.NoError(t, err)
+			transactionService := NewTransactionService(transactionRepositoryMock, rules, givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
+
+			// 	then
+			assert.Equal(t, domain.CardBlocked, account.CardStatus())
+			assert.Equal(t, []error{domain.ErrInsufficientLimit, domain.ErrTooManyDeclines}, violationErrors(errs))
+			assert.Len(t, transactionRepositoryMock.recorded, 2)
+			assert.Equal(t, domain.EventTransactionRejected, transactionRepositoryMock.recorded[0].Type)
+			assert.Equal(t, &domain.CardChange{
+				AccountID: "1",
+				Action:    domain.CardBlock,
+				Reason:    "too-many-declines",
+				CreatedAt: givenClock.now,
+			}, transactionRepositoryMock.recorded[1].CardChange)
 		},
-		"should return error when high frequency of transactions in small interval": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+		"should reject attempt right after the lock as card blocked": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 101}, Merchant: "casino", CreatedAt: givenClock.now}
+			givenAttempt := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 10}, Merchant: "ifood", CreatedAt: givenClock.now.Add(time.Minute)}
+			givenDeclines := []domain.Transaction{
+				{AccountID: "1", Money: domain.Money{Amount: 900}, Merchant: "casino", CreatedAt: givenClock.now.Add(-2 * time.Minute)},
+				{AccountID: "1", Money: domain.Money{Amount: 800}, Merchant: "casino", CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+			}
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, mock.AnythingOfType("Time")).Return(givenActiveAccount, []domain.Transaction{}, nil, givenDeclines)
+
+			rulesConfig := DefaultRulesConfig()
+			rulesConfig.TooManyDeclinesMax = 3
+			rules, err := NewRules(rulesConfig)
+			assert.NoError(t, err)
+			transactionService := NewTransactionService(transactionRepositoryMock, rules, givenClock, TimeBasisEvent, nil)
+			lockedAccount, _ := transactionService.AuthorizeTransaction(givenTransaction)
+			transactionRepositoryMock.On("CommitTransaction", givenAttempt, mock.AnythingOfType("Time")).Return(lockedAccount, []domain.Transaction{}, nil, append(givenDeclines, givenTransaction))
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenAttempt)
+
+			// 	then
+			assert.Equal(t, lockedAccount, account)
+			assert.Equal(t, []error{domain.ErrCardBlocked}, violationErrors(errs))
+			assert.Len(t, transactionRepositoryMock.recorded, 3)
+		},
+		"should not lock card before the decline reaching max declines": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 101}, Merchant: "casino", CreatedAt: givenClock.now}
+			givenDeclines := []domain.Transaction{
+				{AccountID: "1", Money: domain.Money{Amount: 900}, Merchant: "casino", CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+			}
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, mock.AnythingOfType("Time")).Return(givenActiveAccount, []domain.Transaction{}, nil, givenDeclines)
+
+			rulesConfig := DefaultRulesConfig()
+			rulesConfig.TooManyDeclinesMax = 3
+			rules, err := NewRules(rulesConfig)
+			assert.NoError(t, err)
+			transactionService := NewTransactionService(transactionRepositoryMock, rules, givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
+
+			// 	then
+			assert.Equal(t, givenActiveAccount, account)
+			assert.Equal(t, []error{domain.ErrInsufficientLimit}, violationErrors(errs))
+			assert.Len(t, transactionRepositoryMock.recorded, 1)
+		},
+		"should count declines toward high frequency when counting attempts": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{AccountID: "1", Money: domain.Money{Amount: 900}, Merchant: "casino", CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{AccountID: "1", Money: domain.Money{Amount: 800}, Merchant: "casino", CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{AccountID: "1", Money: domain.Money{Amount: 700}, Merchant: "casino", CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+			}
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, mock.AnythingOfType("Time")).Return(givenActiveAccount, []domain.Transaction{}, nil, givenDeclines)
+
+			rulesConfig := DefaultRulesConfig()
+			rulesConfig.HighFrequencyCountAttempts = true
+			rules, err := NewRules(rulesConfig)
+			assert.NoError(t, err)
+			transactionService := NewTransactionService(transactionRepositoryMock, rules, givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
+
+			// 	then
+			assert.Equal(t, givenActiveAccount, account)
+			assert.Equal(t, []error{domain.ErrHighFrequencySmallInterval}, violationErrors(errs))
+		},
+		"should return error when high frequency of transactions in small interval": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
 			foundTransactions := []domain.Transaction{
-				{Merchant: "ifood", Amount: 10, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
-				{Merchant: "uber-eats", Amount: 15, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
-				{Merchant: "mercado", Amount: 20, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{Merchant: "uber-eats", Money: domain.Money{Amount: 15}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{Merchant: "mercado", Money: domain.Money{Amount: 20}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
 			}
 
-			accountServicerMock.On("GetAccount").Return(givenActiveAccount, nil)
-			transactionRepositoryMock.On("FindTransactionsAfter", givenTime).Return(foundTransactions)
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, givenTime).Return(givenActiveAccount, foundTransactions, nil)
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
 			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
 
 			// 	then
 			assert.Equal(t, givenActiveAccount, account)
-			assert.ElementsMatch(t, errs, []error{domain.ErrHighFrequencySmallInterval})
+			assert.ElementsMatch(t, violationErrors(errs), []error{domain.ErrHighFrequencySmallInterval})
 		},
-		"should return error when transactions is doubled in small interval": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+		"should return error when transactions is doubled in small interval": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
 			foundTransactions := []domain.Transaction{
-				{Merchant: "mercado", Amount: 15, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
-				{Merchant: "ifood", Amount: 25, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{Merchant: "mercado", Money: domain.Money{Amount: 15}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
 			}
 
-			accountServicerMock.On("GetAccount").Return(givenActiveAccount, nil)
-			transactionRepositoryMock.On("FindTransactionsAfter", givenTime).Return(foundTransactions)
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, givenTime).Return(givenActiveAccount, foundTransactions, nil)
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
 			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
 
 			// 	then
 			assert.Equal(t, givenActiveAccount, account)
-			assert.ElementsMatch(t, errs, []error{domain.ErrDoubleTransaction})
+			assert.ElementsMatch(t, violationErrors(errs), []error{domain.ErrDoubleTransaction})
 		},
-		"should return list of errors when transaction has multiple violations": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+		"should return list of errors when transaction has multiple violations": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
 			givenAccount := domain.Account{
+				ID:             "1",
 				ActiveCard:     true,
 				AvailableLimit: 24,
 			}
 			foundTransactions := []domain.Transaction{
-				{Merchant: "mercado", Amount: 15, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
-				{Merchant: "ifood", Amount: 25, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
-				{Merchant: "uber-eats", Amount: 100, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{Merchant: "mercado", Money: domain.Money{Amount: 15}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+				{Merchant: "uber-eats", Money: domain.Money{Amount: 100}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
 			}
 
-			accountServicerMock.On("GetAccount").Return(givenAccount, nil)
-			transactionRepositoryMock.On("FindTransactionsAfter", givenTime).Return(foundTransactions)
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, givenTime).Return(givenAccount, foundTransactions, nil)
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
 			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
@@ -132,39 +281,391 @@ func TestAuthorizeTransaction(t *testing.T) {
 				domain.ErrHighFrequencySmallInterval,
 			}
 			assert.Equal(t, givenAccount, account)
-			assert.ElementsMatch(t, errs, wantErrs)
+			assert.ElementsMatch(t, violationErrors(errs), wantErrs)
 		},
-		"should save authorized transaction and set new account limit": func(t *testing.T, accountServicerMock *accountServicerMock, transactionRepositoryMock *transactionRepositoryMock) {
+		"should commit authorized transaction with new account limit": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
 			// 	given
-			givenUpdatedAccount := domain.Account{
+			wantAccount := domain.Account{
+				ID:             "1",
 				ActiveCard:     true,
 				AvailableLimit: 75,
 			}
 
-			accountServicerMock.On("GetAccount").Return(givenActiveAccount, nil)
-			transactionRepositoryMock.On("FindTransactionsAfter", givenTime).Return([]domain.Transaction{})
-			accountServicerMock.On("SetAccountLimit", 75).Return(givenUpdatedAccount)
-			transactionRepositoryMock.On("SaveTransaction", givenTransaction).Return()
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, givenTime).Return(givenActiveAccount, []domain.Transaction{}, nil)
 
-			transactionService := NewTransactionService(transactionRepositoryMock, accountServicerMock)
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
 
 			// 	when
 			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
 
 			// 	then
-			assert.Equal(t, givenUpdatedAccount, account)
+			assert.Equal(t, wantAccount, account)
 			assert.Empty(t, errs)
 		},
+		"should check rules at processing time when time basis is processing": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenFakeTimeTransaction := givenTransaction
+			givenFakeTimeTransaction.CreatedAt = givenClock.now.Add(-time.Hour)
+			foundTransactions := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+			}
+
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, givenTime).Return(givenActiveAccount, foundTransactions, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisProcessing, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenFakeTimeTransaction)
+
+			// 	then
+			assert.Equal(t, givenActiveAccount, account)
+			assert.ElementsMatch(t, violationErrors(errs), []error{domain.ErrDoubleTransaction})
+		},
+		"should convert transaction to account currency": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 10000, Currency: "BRL"}
+			givenForeignTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 1000, Currency: "USD"}, Merchant: "amazon", CreatedAt: givenClock.now}
+			wantTransaction := givenForeignTransaction
+			wantTransaction.Amount = 5250
+			wantTransaction.Currency = "BRL"
+			wantTransaction.Original = &domain.Money{Amount: 1000, Currency: "USD"}
+			givenRates := ExchangeRates{"USD": {"BRL": 5.25}}
+
+			transactionRepositoryMock.On("CommitTransaction", givenForeignTransaction, givenTime).Return(givenAccount, []domain.Transaction{}, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, givenRates)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenForeignTransaction)
+
+			// 	then
+			assert.Equal(t, 4750, account.AvailableLimit)
+			assert.Empty(t, errs)
+			assert.Equal(t, &wantTransaction, transactionRepositoryMock.recorded[0].Transaction)
+			transactionRepositoryMock.AssertNotCalled(t, "FindAccount", "1")
+		},
+		"should return error when there is no rate to account currency": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 10000, Currency: "BRL"}
+			givenForeignTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 1000, Currency: "EUR"}, Merchant: "amazon", CreatedAt: givenClock.now}
+			givenRates := ExchangeRates{"USD": {"BRL": 5.25}}
+
+			transactionRepositoryMock.On("CommitTransaction", givenForeignTransaction, givenTime).Return(givenAccount, []domain.Transaction{}, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, givenRates)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenForeignTransaction)
+
+			// 	then
+			wantErrs := []error{domain.NewViolation(domain.ErrCurrencyNotSupported, "currency EUR is not supported by the account", map[string]interface{}{
+				"currency":         "EUR",
+				"account-currency": "BRL",
+			})}
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, wantErrs, errs)
+		},
+		"should return error when account has no currency": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenForeignTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 1000, Currency: "USD"}, Merchant: "amazon", CreatedAt: givenClock.now}
+
+			transactionRepositoryMock.On("CommitTransaction", givenForeignTransaction, givenTime).Return(givenActiveAccount, []domain.Transaction{}, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, ExchangeRates{"USD": {"BRL": 5.25}})
+
+			// 	when
+			_, errs := transactionService.AuthorizeTransaction(givenForeignTransaction)
+
+			// 	then
+			assert.Equal(t, []error{domain.ErrCurrencyNotSupported}, violationErrors(errs))
+		},
+		"should evaluate given rules with history within their window": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenErr := errors.New("custom-rule")
+			foundTransactions := []domain.Transaction{
+				{Merchant: "mercado", Money: domain.Money{Amount: 15}, CreatedAt: givenClock.now.Add(-4 * time.Minute)},
+			}
+
+			ruleMock := new(ruleMock)
+			ruleMock.On("Window").Return(5 * time.Minute)
+			ruleMock.On("Evaluate", givenActiveAccount, givenTransaction, foundTransactions).Return([]error{givenErr})
+
+			transactionRepositoryMock.On("CommitTransaction", givenTransaction, givenTransaction.CreatedAt.Add(-5*time.Minute)).Return(givenActiveAccount, foundTransactions, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, NewRuleRegistry(ruleMock), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.AuthorizeTransaction(givenTransaction)
+
+			// 	then
+			assert.Equal(t, givenActiveAccount, account)
+			assert.ElementsMatch(t, errs, []error{givenErr})
+			ruleMock.AssertExpectations(t)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			transactionRepositoryMock := new(transactionRepositoryMock)
+
+			run(t, transactionRepositoryMock)
+
+			transactionRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSimulateTransaction(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+	givenAccount := domain.Account{
+		ID:             "1",
+		ActiveCard:     true,
+		AvailableLimit: 100,
+	}
+	givenTransaction := domain.Transaction{
+		AccountID: "1",
+		Money:     domain.Money{Amount: 25},
+		Merchant:  "ifood",
+		CreatedAt: givenClock.now,
+	}
+	givenTime := givenTransaction.CreatedAt.Add(-2 * time.Minute)
+
+	testCases := map[string]func(*testing.T, *transactionRepositoryMock){
+		"should return account as it would be when transaction would be authorized": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("SimulateTransaction", givenTransaction, givenTime).Return(givenAccount, []domain.Transaction{}, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.SimulateTransaction(givenTransaction)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75}, account)
+			assert.Empty(t, errs)
+		},
+		"should return violations transaction would have": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			foundTransactions := []domain.Transaction{
+				{Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenClock.now.Add(-1 * time.Minute)},
+			}
+			transactionRepositoryMock.On("SimulateTransaction", givenTransaction, givenTime).Return(givenAccount, foundTransactions, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.SimulateTransaction(givenTransaction)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.Equal(t, []error{domain.ErrDoubleTransaction}, violationErrors(errs))
+		},
+		"should return error when account not initialized": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("SimulateTransaction", givenTransaction, givenTime).Return(domain.Account{}, nil, domain.ErrAccountNotFound)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.SimulateTransaction(givenTransaction)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.Equal(t, []error{domain.ErrAccountNotInitialized}, errs)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			transactionRepositoryMock := new(transactionRepositoryMock)
+
+			run(t, transactionRepositoryMock)
+
+			transactionRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReverseTransaction(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+	givenAccount := domain.Account{
+		ID:             "1",
+		ActiveCard:     true,
+		AvailableLimit: 75,
+	}
+	givenTransaction := domain.Transaction{
+		ID:        "t1",
+		AccountID: "1",
+		Money:     domain.Money{Amount: 25},
+		Merchant:  "ifood",
+		CreatedAt: givenClock.now,
+	}
+	givenReversal := domain.Reversal{
+		AccountID:     "1",
+		TransactionID: "t1",
+		CreatedAt:     givenClock.now,
+	}
+
+	testCases := map[string]func(*testing.T, *transactionRepositoryMock){
+		"should restore transaction amount to account limit": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("CommitReversal", givenReversal).Return(givenAccount, givenTransaction, true, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.ReverseTransaction(givenReversal)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}, account)
+			assert.Empty(t, errs)
+		},
+		"should reverse transaction at processing time on processing time basis": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenLateReversal := givenReversal
+			givenLateReversal.CreatedAt = givenClock.now.Add(30 * 24 * time.Hour)
+			transactionRepositoryMock.On("CommitReversal", givenReversal).Return(givenAccount, givenTransaction, true, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisProcessing, nil)
+
+			// 	when
+			account, errs := transactionService.ReverseTransaction(givenLateReversal)
+
+			// 	then
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}, account)
+			assert.Empty(t, errs)
+		},
+		"should return error when transaction not found": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("CommitReversal", givenReversal).Return(givenAccount, domain.Transaction{}, false, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.ReverseTransaction(givenReversal)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.ElementsMatch(t, errs, []error{domain.ErrTransactionNotFound})
+		},
+		"should return error when transaction already reversed": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenReversedTransaction := givenTransaction
+			givenReversedTransaction.Reversed = true
+			transactionRepositoryMock.On("CommitReversal", givenReversal).Return(givenAccount, givenReversedTransaction, true, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.ReverseTransaction(givenReversal)
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.ElementsMatch(t, errs, []error{domain.ErrTransactionAlreadyReversed})
+		},
+		"should return error when account not initialized": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("CommitReversal", givenReversal).Return(domain.Account{}, domain.Transaction{}, false, domain.ErrAccountNotFound)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			account, errs := transactionService.ReverseTransaction(givenReversal)
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ElementsMatch(t, errs, []error{domain.ErrAccountNotInitialized})
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			transactionRepositoryMock := new(transactionRepositoryMock)
+
+			run(t, transactionRepositoryMock)
+
+			transactionRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetTransactions(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+	testCases := map[string]func(*testing.T, *transactionRepositoryMock){
+		"should return all transactions of account": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenTransactions := []domain.Transaction{
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenClock.now},
+			}
+			transactionRepositoryMock.On("FindTransactionsAfter", "1", time.Time{}).Return(givenTransactions)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			transactions := transactionService.GetTransactions("1")
+
+			// 	then
+			assert.Equal(t, givenTransactions, transactions)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			transactionRepositoryMock := new(transactionRepositoryMock)
+
+			run(t, transactionRepositoryMock)
+
+			transactionRepositoryMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetDecisions(t *testing.T) {
+	givenClock := fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+	givenFilter := domain.DecisionFilter{Outcome: domain.OutcomeRejected}
+
+	testCases := map[string]func(*testing.T, *transactionRepositoryMock){
+		"should return decisions of account matching filter": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			givenDecisions := []domain.Decision{{
+				Outcome:     domain.OutcomeRejected,
+				Transaction: domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 250}, CreatedAt: givenClock.now},
+				Account:     domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100},
+				Violations:  []string{"insufficient-limit"},
+				DecidedAt:   givenClock.now,
+			}}
+			transactionRepositoryMock.On("FindDecisions", "1", givenFilter).Return(givenDecisions, nil)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			decisions, err := transactionService.GetDecisions("1", givenFilter)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, givenDecisions, decisions)
+		},
+		"should return error when account not initialized": func(t *testing.T, transactionRepositoryMock *transactionRepositoryMock) {
+			// 	given
+			transactionRepositoryMock.On("FindDecisions", "1", givenFilter).Return(nil, domain.ErrAccountNotFound)
+
+			transactionService := NewTransactionService(transactionRepositoryMock, DefaultRules(), givenClock, TimeBasisEvent, nil)
+
+			// 	when
+			decisions, err := transactionService.GetDecisions("1", givenFilter)
+
+			// 	then
+			assert.Empty(t, decisions)
+			assert.Equal(t, domain.ErrAccountNotInitialized, err)
+		},
 	}
 
 	for name, run := range testCases {
 		t.Run(name, func(t *testing.T) {
-			accountServicerMock := new(accountServicerMock)
 			transactionRepositoryMock := new(transactionRepositoryMock)
 
-			run(t, accountServicerMock, transactionRepositoryMock)
+			run(t, transactionRepositoryMock)
 
-			accountServicerMock.AssertExpectations(t)
 			transactionRepositoryMock.AssertExpectations(t)
 		})
 	}
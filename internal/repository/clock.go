@@ -0,0 +1,17 @@
+package repository
+
+import "time"
+
+type (
+	// Clock tells the current time the events are recorded at, which the idempotency key retention is judged by.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// systemClock tells the current time of the system.
+	systemClock struct{}
+)
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockDir takes the exclusive lock of the lock file at path, creating it when needed, failing with errDirLocked when
+// another process holds it. The lock is released when the returned file is closed.
+func lockDir(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errDirLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
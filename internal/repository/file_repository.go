@@ -0,0 +1,688 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+const (
+	logFileName      = "authorizer.wal"
+	snapshotFileName = "authorizer.snapshot"
+	lockFileName     = "authorizer.lock"
+
+	// readOnlyAttempts is how many times a read-only repository is opened again when the log was cut by a snapshot of
+	// the writer while being read.
+	readOnlyAttempts = 3
+)
+
+var (
+	errReadOnly     = errors.New("repository is read-only")
+	errDirLocked    = errors.New("data dir is locked by another process")
+	errLogRewritten = errors.New("log was rewritten while being read")
+)
+
+type (
+	// FileRepository keeps the account ledgers in memory and makes them durable in a write-ahead log under dir: every
+	// event is appended and fsynced to the log before being applied, and the log is replayed when the repository is
+	// opened. Every snapshotEvery events the state held in memory is written to a snapshot and the log is truncated.
+	//
+	// An event that fails to be logged is neither applied nor kept in the log, and the commit returns the error. When
+	// the log can not be cut back to its last event, the repository is broken and refuses any further change.
+	//
+	// The dir is locked while the repository is open, so no other process appends to the same log. A read-only
+	// repository takes no lock and refuses any change.
+	FileRepository struct {
+		mutex               *sync.Mutex
+		clock               Clock
+		memory              MemoryRepository
+		dir                 string
+		dirLock             *os.File
+		readOnly            bool
+		log                 *os.File
+		logSize             int64
+		sequence            uint64
+		eventsSinceSnapshot int
+		snapshotEvery       int
+		snapshotErr         error
+		brokenErr           error
+	}
+)
+
+// NewFileRepository opens the repository stored in dir, creating it when needed, and replays its snapshot and log.
+// A snapshotEvery of zero disables automatic snapshots, and the retentions bound the indexed transactions, the kept
+// idempotency keys and the history like the ones of NewMemoryRepositoryWithRetention. Events are logged at the time the
+// clock tells. The dir stays locked until the repository is closed, opening it while another process has it open fails.
+func NewFileRepository(
+	dir string,
+	snapshotEvery int,
+	retention time.Duration,
+	keyRetention time.Duration,
+	historyRetention time.Duration,
+	clock Clock,
+) (FileRepository, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return FileRepository{}, err
+	}
+
+	dirLock, err := lockDir(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return FileRepository{}, err
+	}
+
+	f := newFileRepository(dir, snapshotEvery, retention, keyRetention, historyRetention, clock)
+	f.dirLock = dirLock
+
+	if err := f.loadSnapshot(); err != nil {
+		dirLock.Close()
+		return FileRepository{}, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		dirLock.Close()
+		return FileRepository{}, err
+	}
+	f.log = log
+
+	if err := f.replayLog(); err != nil {
+		log.Close()
+		dirLock.Close()
+		return FileRepository{}, err
+	}
+
+	return f, nil
+}
+
+// OpenFileRepositoryReadOnly opens the repository stored in dir only to read it, replaying its snapshot and log without
+// locking the dir or writing to it, so it can be read while another process has it open. A last event still being
+// written is left out, and the repository is opened again when a snapshot cuts the log while it is read.
+func OpenFileRepositoryReadOnly(
+	dir string,
+	retention time.Duration,
+	keyRetention time.Duration,
+	historyRetention time.Duration,
+	clock Clock,
+) (FileRepository, error) {
+	for attempt := 1; ; attempt++ {
+		f, err := openReadOnly(dir, retention, keyRetention, historyRetention, clock)
+		if errors.Is(err, errLogRewritten) && attempt < readOnlyAttempts {
+			continue
+		}
+		return f, err
+	}
+}
+
+func openReadOnly(
+	dir string,
+	retention time.Duration,
+	keyRetention time.Duration,
+	historyRetention time.Duration,
+	clock Clock,
+) (FileRepository, error) {
+	f := newFileRepository(dir, 0, retention, keyRetention, historyRetention, clock)
+	f.readOnly = true
+
+	if err := f.loadSnapshot(); err != nil {
+		return FileRepository{}, err
+	}
+
+	log, err := os.Open(filepath.Join(dir, logFileName))
+	if err != nil {
+		return FileRepository{}, err
+	}
+	f.log = log
+
+	if err := f.replayLog(); err != nil {
+		log.Close()
+		return FileRepository{}, err
+	}
+
+	return f, nil
+}
+
+func newFileRepository(
+	dir string,
+	snapshotEvery int,
+	retention time.Duration,
+	keyRetention time.Duration,
+	historyRetention time.Duration,
+	clock Clock,
+) FileRepository {
+	return FileRepository{
+		mutex:         &sync.Mutex{},
+		clock:         clock,
+		memory:        NewMemoryRepositoryWithRetention(retention, keyRetention, historyRetention, clock),
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+	}
+}
+
+// Close closes the log and unlocks the dir, returning the error of the last automatic snapshot when it failed, since
+// the log was not truncated then.
+func (f *FileRepository) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.log.Close(); err != nil {
+		return err
+	}
+	if f.dirLock != nil {
+		if err := f.dirLock.Close(); err != nil {
+			return err
+		}
+	}
+	return f.snapshotErr
+}
+
+func (f *FileRepository) FindTransactionsAfter(accountID string, time time.Time) []domain.Transaction {
+	return f.memory.FindTransactionsAfter(accountID, time)
+}
+
+// SpentBetween returns the amount the account spent from the given time, inclusive, to the other, exclusive.
+func (f *FileRepository) SpentBetween(accountID string, from time.Time, to time.Time) int {
+	return f.memory.SpentBetween(accountID, from, to)
+}
+
+// CommitTransaction holds the repository lock while authorizing over the account, its transactions after the given
+// time and its spending, then logs the transaction as authorized or rejected, a rejection along with the card change of
+// the authorization in the same write.
+func (f *FileRepository) CommitTransaction(
+	transaction domain.Transaction,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if result, found := f.findKeyResult(transaction); found {
+		if !result.matches(transaction) {
+			return result.account, nil, domain.ErrKeyReused
+		}
+		return result.account, result.errors(), nil
+	}
+
+	if err := f.expireHolds(transaction.AccountID, transaction.CreatedAt); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err := f.memory.FindAccount(transaction.AccountID)
+	if err != nil {
+		return domain.Account{}, nil, err
+	}
+	if f.idTaken(transaction.AccountID, transaction.ID) {
+		return account, nil, domain.ErrTransactionExists
+	}
+
+	authorization := authorize(
+		account,
+		f.memory.FindTransactionsAfter(transaction.AccountID, after),
+		f.memory.FindDeclinesAfter(transaction.AccountID, after),
+		f.spendingOf(transaction.AccountID),
+	)
+	errs := authorization.Violations
+	if len(errs) > 0 {
+		events := []domain.Event{domain.NewTransactionRejected(authorization.Transaction, errs, f.clock.Now().UTC())}
+		if authorization.CardChange != nil {
+			events = append(events, domain.NewCardChanged(*authorization.CardChange, f.clock.Now().UTC()))
+		}
+		if err := f.append(events...); err != nil {
+			return domain.Account{}, nil, err
+		}
+		account, err = f.memory.FindAccount(transaction.AccountID)
+		return account, errs, err
+	}
+
+	if err := f.append(domain.NewTransactionAuthorized(authorization.Transaction, f.clock.Now().UTC())); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err = f.memory.FindAccount(transaction.AccountID)
+	return account, errs, err
+}
+
+// SimulateTransaction holds the repository lock while authorizing the transaction like CommitTransaction, logging
+// nothing.
+func (f *FileRepository) SimulateTransaction(
+	transaction domain.Transaction,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.memory.SimulateTransaction(transaction, after, authorize)
+}
+
+// CommitReversal holds the repository lock while validating the reversal over the account and the authorized
+// transaction it references, then logs the holds expired by the reversal time and the reversal when there are no
+// violations.
+func (f *FileRepository) CommitReversal(
+	reversal domain.Reversal,
+	validate func(account domain.Account, transaction domain.Transaction, found bool) []error,
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	account, err := f.memory.FindAccount(reversal.AccountID)
+	if err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	transaction, found := f.memory.FindTransaction(reversal.AccountID, reversal.TransactionID)
+	if errs := validate(account, transaction, found); len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	if err := f.expireHolds(reversal.AccountID, reversal.CreatedAt); err != nil {
+		return domain.Account{}, nil, err
+	}
+	if err := f.append(domain.NewTransactionReversed(transaction, reversal, f.clock.Now().UTC())); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err = f.memory.FindAccount(reversal.AccountID)
+	return account, []error{}, err
+}
+
+// CommitHold holds the repository lock while authorizing the hold like a transaction, then logs the hold authorize
+// returns as placed when there are no violations.
+func (f *FileRepository) CommitHold(
+	hold domain.Hold,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) (domain.Hold, []error),
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.expireHolds(hold.AccountID, hold.CreatedAt); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err := f.memory.FindAccount(hold.AccountID)
+	if err != nil {
+		return domain.Account{}, nil, err
+	}
+	if f.idTaken(hold.AccountID, hold.ID) {
+		return account, nil, domain.ErrHoldExists
+	}
+
+	placed, errs := authorize(
+		account,
+		f.memory.FindTransactionsAfter(hold.AccountID, after),
+		f.memory.FindDeclinesAfter(hold.AccountID, after),
+		f.spendingOf(hold.AccountID),
+	)
+	if len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	if err := f.append(domain.NewHoldPlaced(placed, f.clock.Now().UTC())); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err = f.memory.FindAccount(hold.AccountID)
+	return account, []error{}, err
+}
+
+// CommitCapture holds the repository lock while validating the capture over the account and the hold it references,
+// then logs the capture validate returns when there are no violations.
+func (f *FileRepository) CommitCapture(
+	capture domain.Capture,
+	validate func(account domain.Account, hold domain.Hold, found bool) (domain.Capture, []error),
+) (domain.Account, []error, error) {
+	return f.commitSettlement(capture.AccountID, capture.HoldID, capture.CreatedAt, func(account domain.Account, hold domain.Hold, found bool) (domain.Event, []error) {
+		captured, errs := validate(account, hold, found)
+		return domain.NewHoldCaptured(hold, captured, f.clock.Now().UTC()), errs
+	})
+}
+
+// CommitRelease holds the repository lock while validating the release over the account and the hold it references,
+// then logs the release when there are no violations.
+func (f *FileRepository) CommitRelease(
+	release domain.Release,
+	validate func(account domain.Account, hold domain.Hold, found bool) []error,
+) (domain.Account, []error, error) {
+	return f.commitSettlement(release.AccountID, release.HoldID, release.CreatedAt, func(account domain.Account, hold domain.Hold, found bool) (domain.Event, []error) {
+		return domain.NewHoldReleased(hold, release, f.clock.Now().UTC()), validate(account, hold, found)
+	})
+}
+
+func (f *FileRepository) SaveAccount(account domain.Account) (domain.Account, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if savedAccount, err := f.memory.FindAccount(account.ID); err == nil {
+		return savedAccount, domain.ErrAccountExists
+	}
+
+	if err := f.append(domain.NewAccountCreated(account, f.clock.Now().UTC())); err != nil {
+		return domain.Account{}, err
+	}
+
+	return account, nil
+}
+
+func (f *FileRepository) FindAccount(accountID string) (domain.Account, error) {
+	return f.memory.FindAccount(accountID)
+}
+
+// CommitCardChange holds the repository lock while validating the card change over the account, then logs it when there
+// are no violations.
+func (f *FileRepository) CommitCardChange(
+	change domain.CardChange,
+	validate func(domain.Account) []error,
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	account, err := f.memory.FindAccount(change.AccountID)
+	if err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	if errs := validate(account); len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	if err := f.append(domain.NewCardChanged(change, f.clock.Now().UTC())); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err = f.memory.FindAccount(change.AccountID)
+	return account, []error{}, err
+}
+
+// CommitLimitChange holds the repository lock while validating the limit change over the account, then logs it when
+// there are no violations.
+func (f *FileRepository) CommitLimitChange(
+	change domain.LimitChange,
+	validate func(domain.Account) []error,
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	account, err := f.memory.FindAccount(change.AccountID)
+	if err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	if errs := validate(account); len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	if err := f.append(domain.NewTotalLimitChanged(change, f.clock.Now().UTC())); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err = f.memory.FindAccount(change.AccountID)
+	return account, []error{}, err
+}
+
+func (f *FileRepository) FindEvents(accountID string) []domain.Event {
+	return f.memory.FindEvents(accountID)
+}
+
+func (f *FileRepository) FindDecisions(accountID string, filter domain.DecisionFilter) ([]domain.Decision, error) {
+	return f.memory.FindDecisions(accountID, filter)
+}
+
+// Snapshot writes the state held in memory to the snapshot file and truncates the log.
+func (f *FileRepository) Snapshot() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.readOnly {
+		return errReadOnly
+	}
+
+	f.snapshotErr = f.snapshot()
+	return f.snapshotErr
+}
+
+func (f *FileRepository) commitSettlement(
+	accountID string,
+	holdID string,
+	at time.Time,
+	settle func(account domain.Account, hold domain.Hold, found bool) (domain.Event, []error),
+) (domain.Account, []error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.expireHolds(accountID, at); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err := f.memory.FindAccount(accountID)
+	if err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	hold, found := f.memory.FindHold(accountID, holdID)
+	event, errs := settle(account, hold, found)
+	if len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	if err := f.append(event); err != nil {
+		return domain.Account{}, nil, err
+	}
+
+	account, err = f.memory.FindAccount(accountID)
+	return account, []error{}, err
+}
+
+// idTaken tells whether the account has a hold or authorized transaction with the given id, it must be called holding
+// the lock.
+func (f *FileRepository) idTaken(accountID string, id string) bool {
+	f.memory.mutex.RLock()
+	defer f.memory.mutex.RUnlock()
+
+	return f.memory.idTaken(accountID, id)
+}
+
+// spendingOf returns the spending of the account, it must be called holding the lock so no event is applied while it is
+// read.
+func (f *FileRepository) spendingOf(accountID string) domain.Spending {
+	f.memory.mutex.RLock()
+	defer f.memory.mutex.RUnlock()
+
+	return f.memory.spendingOf(accountID)
+}
+
+// findKeyResult returns the recorded result of the transaction with the idempotency key of the given one, it must be
+// called holding the lock.
+func (f *FileRepository) findKeyResult(transaction domain.Transaction) (keyResult, bool) {
+	f.memory.mutex.RLock()
+	defer f.memory.mutex.RUnlock()
+
+	return f.memory.findKeyResult(transaction)
+}
+
+// expireHolds logs the expiration of the holds of the account past their expiration at the given time, it must be
+// called holding the lock.
+func (f *FileRepository) expireHolds(accountID string, at time.Time) error {
+	for _, hold := range f.memory.FindExpiredHolds(accountID, at) {
+		if err := f.append(domain.NewHoldExpired(hold, f.clock.Now().UTC())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// append writes the events of one commit to the log in a single write, fsyncs it and only then applies them, it must be
+// called holding the lock. Events that fail to be logged are cut back out of the log, so none of them is applied and a
+// later event does not follow a torn line.
+func (f *FileRepository) append(events ...domain.Event) error {
+	if f.readOnly {
+		return errReadOnly
+	}
+	if f.brokenErr != nil {
+		return f.brokenErr
+	}
+
+	var data []byte
+	for i := range events {
+		events[i].Sequence = f.sequence + uint64(i) + 1
+		line, err := json.Marshal(events[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if _, err := f.log.Write(data); err != nil {
+		return f.cutLog(fmt.Errorf("failed to write log: %w", err))
+	}
+	if err := f.log.Sync(); err != nil {
+		return f.cutLog(fmt.Errorf("failed to sync log: %w", err))
+	}
+	f.logSize += int64(len(data))
+
+	for _, event := range events {
+		f.apply(event)
+	}
+
+	// a failed snapshot is retried on the next event, the log still holds every event until then.
+	if f.snapshotEvery > 0 && f.eventsSinceSnapshot >= f.snapshotEvery {
+		f.snapshotErr = f.snapshot()
+	}
+	return nil
+}
+
+// cutLog truncates the log back to its last logged event after the given failure to log another one, breaking the
+// repository when it can not.
+func (f *FileRepository) cutLog(err error) error {
+	if truncateErr := f.log.Truncate(f.logSize); truncateErr != nil {
+		f.brokenErr = fmt.Errorf("%v, then failed to truncate log: %w", err, truncateErr)
+		return f.brokenErr
+	}
+	return err
+}
+
+func (f *FileRepository) apply(event domain.Event) {
+	f.memory.applyEvents(event)
+
+	f.sequence = event.Sequence
+	f.eventsSinceSnapshot++
+}
+
+// snapshot replaces the snapshot file atomically with the state held in memory and then truncates the log, events
+// already in the snapshot are skipped by their sequence when a crash happens in between.
+func (f *FileRepository) snapshot() error {
+	data, err := json.Marshal(f.memory.state())
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if err := writeFileSync(filepath.Join(f.dir, snapshotFileName), data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := f.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate log: %w", err)
+	}
+	if err := f.log.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+
+	f.logSize = 0
+	f.eventsSinceSnapshot = 0
+	return nil
+}
+
+func (f *FileRepository) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(f.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state := memoryState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	f.memory.restore(state)
+	f.sequence = state.Sequence
+
+	return nil
+}
+
+// replayLog applies the logged events newer than the snapshot. A last event without its line break was torn by a crash
+// while being written, so it was never applied and is truncated away. A read-only repository leaves it in the log, as
+// the writer may still be writing it, and fails with errLogRewritten when the log skips events, cut by a snapshot taken
+// after the one it loaded.
+func (f *FileRepository) replayLog() error {
+	reader := bufio.NewReader(f.log)
+
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			f.logSize = offset
+			if len(data) > 0 && !f.readOnly {
+				return f.log.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		event := domain.Event{}
+		if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+			return fmt.Errorf("failed to parse log line %d: %w", line, err)
+		}
+		offset += int64(len(data))
+
+		if event.Sequence <= f.sequence {
+			continue
+		}
+		if f.readOnly && event.Sequence > f.sequence+1 {
+			return errLogRewritten
+		}
+		f.apply(event)
+	}
+}
+
+// writeFileSync writes data to a temporary file and renames it over path, syncing both the file and its directory.
+func writeFileSync(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,603 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestFileRepository(t *testing.T) {
+	givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100}
+	givenTransaction := domain.Transaction{
+		AccountID: "1",
+		Merchant:  "ifood",
+		Money:     domain.Money{Amount: 25},
+		CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+	}
+	validate := func(domain.Account) []error {
+		return nil
+	}
+
+	testCases := map[string]func(*testing.T, string){
+		"should restore state from log when reopened": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 75}, validate)
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 50, TotalLimit: 75}, account)
+			assert.Equal(t, []domain.Transaction{givenTransaction}, reopenedRepository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should not log simulated transactions": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			account, _, err := repository.SimulateTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Len(t, reopenedRepository.FindEvents("1"), 1)
+			assert.Empty(t, reopenedRepository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should restore rejected transactions only in ledger": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction, domain.ErrInsufficientLimit))
+			assert.NoError(t, err)
+			assert.NotEmpty(t, errs)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, givenAccount, account)
+			assert.Empty(t, reopenedRepository.FindTransactionsAfter("1", time.Time{}))
+			assert.Equal(t, repository.FindEvents("1"), reopenedRepository.FindEvents("1"))
+			decisions, err := reopenedRepository.FindDecisions("1", domain.DecisionFilter{Outcome: domain.OutcomeRejected})
+			assert.NoError(t, err)
+			assert.Len(t, decisions, 1)
+			assert.Equal(t, []string{"insufficient-limit"}, decisions[0].Violations)
+		},
+		"should log card change of rejected transaction in the same write": func(t *testing.T, dir string) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardBlock, Reason: "too-many-declines", CreatedAt: givenTransaction.CreatedAt}
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, lockAs(givenTransaction, givenChange, domain.ErrTooManyDeclines))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, domain.CardBlocked, account.CardStatus())
+			events := reopenedRepository.FindEvents("1")
+			assert.Equal(t, []uint64{1, 2, 3}, []uint64{events[0].Sequence, events[1].Sequence, events[2].Sequence})
+			assert.Equal(t, &givenChange, events[2].CardChange)
+			assert.Len(t, readLog(t, dir), 3)
+		},
+		"should restore idempotency keys when reopened": func(t *testing.T, dir string) {
+			// 	given
+			givenTransaction := givenTransaction
+			givenTransaction.IdempotencyKey = "k1"
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+			account, errs, err := reopenedRepository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Len(t, reopenedRepository.FindEvents("1"), 2)
+		},
+		"should restore transactions of idempotency keys from snapshot": func(t *testing.T, dir string) {
+			// 	given
+			givenTransaction := givenTransaction
+			givenTransaction.IdempotencyKey = "k1"
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Snapshot())
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+			givenOtherTransaction := givenTransaction
+			givenOtherTransaction.Merchant = "other"
+			_, _, err = reopenedRepository.CommitTransaction(givenOtherTransaction, time.Time{}, authorizeAs(givenOtherTransaction))
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrKeyReused)
+			assert.Len(t, reopenedRepository.FindEvents("1"), 2)
+		},
+		"should restore violations of idempotency keys when reopened": func(t *testing.T, dir string) {
+			// 	given
+			givenTransaction := givenTransaction
+			givenTransaction.IdempotencyKey = "k1"
+			givenViolation := domain.NewViolation(domain.ErrInsufficientLimit, "amount exceeds the available limit", map[string]interface{}{
+				"available": 100,
+				"requested": 250,
+			})
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction, givenViolation, domain.ErrDoubleTransaction))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+			_, errs, err := reopenedRepository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Len(t, errs, 2)
+			assert.True(t, errors.Is(errs[0], domain.ErrInsufficientLimit))
+			assert.Equal(t, givenViolation.Message, domain.ViolationOf(errs[0]).Message)
+			assert.Equal(t, map[string]interface{}{"available": 100.0, "requested": 250.0}, domain.ViolationOf(errs[0]).Metadata)
+			assert.Equal(t, domain.ErrDoubleTransaction, errs[1])
+		},
+		"should restore reversals when reopened": func(t *testing.T, dir string) {
+			// 	given
+			givenTransaction := givenTransaction
+			givenTransaction.ID = "t1"
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitReversal(domain.Reversal{AccountID: "1", TransactionID: "t1"}, func(domain.Account, domain.Transaction, bool) []error {
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, givenAccount, account)
+			transaction, found := reopenedRepository.memory.FindTransaction("1", "t1")
+			assert.True(t, found)
+			assert.True(t, transaction.Reversed)
+		},
+		"should restore limit changes when reopened": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 200}, func(domain.Account) []error {
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 175, TotalLimit: 200}, account)
+		},
+		"should restore holds when reopened": func(t *testing.T, dir string) {
+			// 	given
+			givenHold := domain.Hold{
+				ID:        "h1",
+				AccountID: "1",
+				Money:     domain.Money{Amount: 60},
+				Merchant:  "hotel",
+				CreatedAt: givenTransaction.CreatedAt,
+				ExpiresAt: givenTransaction.CreatedAt.Add(time.Hour),
+			}
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			expiringTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 10}, CreatedAt: givenHold.ExpiresAt}
+			_, _, err = repository.CommitTransaction(expiringTransaction, time.Time{}, authorizeAs(expiringTransaction))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, 90, account.AvailableLimit)
+			hold, found := reopenedRepository.memory.FindHold("1", "h1")
+			assert.True(t, found)
+			assert.Equal(t, domain.HoldExpired, hold.Status)
+			assert.Len(t, readLog(t, dir), 4)
+		},
+		"should log events at the clock time": func(t *testing.T, dir string) {
+			// 	given
+			givenClock := &fakeClock{now: time.Date(2019, 02, 13, 12, 0, 0, 0, time.UTC)}
+			repository, err := NewFileRepository(dir, 0, 0, 0, 0, givenClock)
+			assert.NoError(t, err)
+			defer repository.Close()
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt, ExpiresAt: givenTransaction.CreatedAt.Add(time.Hour)}
+
+			// 	when
+			_, err = repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			givenClock.advance(time.Hour)
+			expiringTransaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 10}, CreatedAt: givenHold.ExpiresAt}
+			_, _, err = repository.CommitTransaction(expiringTransaction, time.Time{}, authorizeAs(expiringTransaction))
+			assert.NoError(t, err)
+
+			// 	then
+			events := repository.FindEvents("1")
+			assert.Equal(t, domain.EventHoldExpired, events[2].Type)
+			assert.Equal(t, givenClock.now, events[2].RecordedAt)
+			assert.Equal(t, givenClock.now.Add(-time.Hour), events[1].RecordedAt)
+		},
+		"should trim history again when log is replayed": func(t *testing.T, dir string) {
+			// 	given
+			givenClock := &fakeClock{now: time.Date(2019, 02, 13, 12, 0, 0, 0, time.UTC)}
+			repository, err := NewFileRepository(dir, 0, 0, 0, time.Hour, givenClock)
+			assert.NoError(t, err)
+			_, err = repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			givenClock.advance(2 * time.Hour)
+			_, _, err = repository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 75}, validate)
+			assert.NoError(t, err)
+			events := repository.FindEvents("1")
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository, err := NewFileRepository(dir, 0, 0, 0, time.Hour, givenClock)
+			assert.NoError(t, err)
+			defer reopenedRepository.Close()
+
+			// 	then
+			assert.Len(t, events, 2)
+			assert.Equal(t, domain.EventHistoryTrimmed, events[0].Type)
+			assert.Equal(t, events, reopenedRepository.FindEvents("1"))
+		},
+		"should snapshot and compact log every given events": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 2)
+
+			// 	when
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 75}, validate)
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			// 	then
+			assert.FileExists(t, filepath.Join(dir, snapshotFileName))
+			assert.Len(t, readLog(t, dir), 1)
+
+			reopenedRepository := openFileRepository(t, dir, 2)
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, 50, account.AvailableLimit)
+			assert.Equal(t, []domain.Transaction{givenTransaction}, reopenedRepository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should skip logged events already in snapshot": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+
+			// a crash right after the snapshot is written leaves the log uncompacted
+			logData, err := os.ReadFile(filepath.Join(dir, logFileName))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Snapshot())
+			assert.NoError(t, repository.Close())
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, logFileName), logData, 0600))
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Equal(t, []domain.Transaction{givenTransaction}, reopenedRepository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should truncate torn event at the end of log": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Close())
+
+			log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0600)
+			assert.NoError(t, err)
+			_, err = log.WriteString(`{"seq":2,"type":"account-upd`)
+			assert.NoError(t, err)
+			assert.NoError(t, log.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+			_, _, err = reopenedRepository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 50}, validate)
+			assert.NoError(t, err)
+
+			// 	then
+			account, err := reopenedRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, 50, account.AvailableLimit)
+			assert.Len(t, readLog(t, dir), 2)
+		},
+		"should leave torn event at the end of log when read-only": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+
+			log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0600)
+			assert.NoError(t, err)
+			_, err = log.WriteString(`{"seq":2,"type":"account-upd`)
+			assert.NoError(t, err)
+			assert.NoError(t, log.Close())
+
+			// 	when
+			readOnlyRepository, err := OpenFileRepositoryReadOnly(dir, 0, 0, 0, systemClock{})
+			assert.NoError(t, err)
+			defer readOnlyRepository.Close()
+
+			// 	then
+			account, err := readOnlyRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, givenAccount, account)
+			data, err := os.ReadFile(filepath.Join(dir, logFileName))
+			assert.NoError(t, err)
+			assert.True(t, strings.HasSuffix(string(data), `{"seq":2,"type":"account-upd`))
+		},
+		"should read log open by another repository and refuse changes when read-only": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+
+			// 	when
+			readOnlyRepository, err := OpenFileRepositoryReadOnly(dir, 0, 0, 0, systemClock{})
+			assert.NoError(t, err)
+			defer readOnlyRepository.Close()
+			_, _, commitErr := readOnlyRepository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 50}, validate)
+
+			// 	then
+			account, err := readOnlyRepository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, givenAccount, account)
+			assert.ErrorIs(t, commitErr, errReadOnly)
+			assert.ErrorIs(t, readOnlyRepository.Snapshot(), errReadOnly)
+			assert.Len(t, readLog(t, dir), 1)
+		},
+		"should replay legacy limit adjustments": func(t *testing.T, dir string) {
+			// 	given
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, logFileName), []byte(
+				`{"seq":1,"type":"account-created","account-id":"1","account":{"account-id":"1","active-card":true,"available-limit":100}}`+"\n"+
+					`{"seq":2,"type":"limit-adjusted","account-id":"1","adjustment":-30}`+"\n",
+			), 0600))
+
+			// 	when
+			repository := openFileRepository(t, dir, 0)
+
+			// 	then
+			account, err := repository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, 70, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should return error when log skips events of the snapshot read": func(t *testing.T, dir string) {
+			// 	given
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, logFileName), []byte(`{"seq":3,"type":"account-created","account-id":"1"}`+"\n"), 0600))
+
+			// 	when
+			_, err := OpenFileRepositoryReadOnly(dir, 0, 0, 0, systemClock{})
+
+			// 	then
+			assert.ErrorIs(t, err, errLogRewritten)
+		},
+		"should return error when dir is open by another repository": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			// 	when
+			_, err := NewFileRepository(dir, 0, 0, 0, 0, systemClock{})
+
+			// 	then
+			assert.ErrorIs(t, err, errDirLocked)
+			assert.NoError(t, repository.Close())
+			reopenedRepository, err := NewFileRepository(dir, 0, 0, 0, 0, systemClock{})
+			assert.NoError(t, err)
+			assert.NoError(t, reopenedRepository.Close())
+		},
+		"should return error when log is corrupted": func(t *testing.T, dir string) {
+			// 	given
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, logFileName), []byte("not json\n{}\n"), 0600))
+
+			// 	when
+			_, err := NewFileRepository(dir, 0, 0, 0, 0, systemClock{})
+
+			// 	then
+			assert.EqualError(t, err, "failed to parse log line 1: invalid character 'o' in literal null (expecting 'u')")
+		},
+		"should restore holds, spending, declines, decisions and idempotency keys from snapshot": func(t *testing.T, dir string) {
+			// 	given
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 30}, Merchant: "hotel", CreatedAt: givenTransaction.CreatedAt, ExpiresAt: givenTransaction.CreatedAt.Add(time.Hour)}
+			givenKeyedTransaction := givenTransaction
+			givenKeyedTransaction.IdempotencyKey = "k1"
+			givenDecline := domain.Transaction{AccountID: "1", Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: givenTransaction.CreatedAt}
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenKeyedTransaction, time.Time{}, authorizeAs(givenKeyedTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenDecline, time.Time{}, authorizeAs(givenDecline, domain.ErrInsufficientLimit))
+			assert.NoError(t, err)
+			assert.NoError(t, repository.Snapshot())
+			assert.NoError(t, repository.Close())
+
+			// 	when
+			reopenedRepository := openFileRepository(t, dir, 0)
+
+			// 	then
+			assert.Empty(t, readLog(t, dir)[0])
+			account, errs, err := reopenedRepository.CommitTransaction(givenKeyedTransaction, time.Time{}, authorizeAs(givenKeyedTransaction, domain.ErrDoubleTransaction))
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 45, account.AvailableLimit)
+			hold, found := reopenedRepository.memory.FindHold("1", "h1")
+			assert.True(t, found)
+			assert.Equal(t, domain.HoldHeld, hold.Status)
+			assert.Equal(t, 55, reopenedRepository.SpentBetween("1", givenTransaction.CreatedAt, givenTransaction.CreatedAt.Add(time.Second)))
+			assert.Equal(t, []domain.Transaction{givenDecline}, reopenedRepository.memory.FindDeclinesAfter("1", time.Time{}))
+			decisions, err := reopenedRepository.FindDecisions("1", domain.DecisionFilter{})
+			assert.NoError(t, err)
+			assert.Len(t, decisions, 2)
+			assert.Len(t, reopenedRepository.FindEvents("1"), 4)
+		},
+		"should return error and apply nothing when log can not be written": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			assert.NoError(t, repository.log.Close())
+
+			// 	when
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+
+			// 	then
+			assert.Error(t, err)
+			account, err := repository.FindAccount("1")
+			assert.NoError(t, err)
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 1)
+			_, _, err = repository.CommitLimitChange(domain.LimitChange{AccountID: "1", TotalLimit: 75}, validate)
+			assert.Error(t, err)
+		},
+		"should report failed snapshot when closed": func(t *testing.T, dir string) {
+			// 	given
+			assert.NoError(t, os.Mkdir(filepath.Join(dir, snapshotFileName+".tmp"), 0700))
+			repository := openFileRepository(t, dir, 1)
+
+			// 	when
+			_, err := repository.SaveAccount(givenAccount)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Len(t, readLog(t, dir), 1)
+			err = repository.Close()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "failed to write snapshot")
+		},
+		"should return error when account already initialized": func(t *testing.T, dir string) {
+			// 	given
+			repository := openFileRepository(t, dir, 0)
+
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+
+			// 	when
+			account, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 300})
+
+			// 	then
+			assert.Equal(t, givenAccount, account)
+			assert.ErrorIs(t, err, domain.ErrAccountExists)
+			assert.Len(t, readLog(t, dir), 1)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t, t.TempDir())
+		})
+	}
+}
+
+func openFileRepository(t *testing.T, dir string, snapshotEvery int) *FileRepository {
+	repository, err := NewFileRepository(dir, snapshotEvery, 0, 0, 0, systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		repository.Close()
+	})
+	return &repository
+}
+
+func readLog(t *testing.T, dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
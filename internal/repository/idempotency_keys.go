@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+type (
+	// idempotencyKeys keeps the results of the transactions recorded with an idempotency key, so a retried transaction
+	// is answered with its result instead of being authorized again. With a retention, results are kept only for the
+	// retention after they were recorded; they are added in recording order, so the expired ones are evicted from the
+	// front.
+	idempotencyKeys struct {
+		retention time.Duration
+		results   map[idempotencyKey]keyResult
+		order     []idempotencyKey
+	}
+
+	// idempotencyKey scopes a key to its account, so accounts can not see each other's results.
+	idempotencyKey struct {
+		accountID string
+		key       string
+	}
+
+	// keyResult is the account right after a keyed transaction was recorded, along with its violations and the
+	// transaction as recorded, which is nil for the results restored from a snapshot that did not keep it.
+	keyResult struct {
+		account     domain.Account
+		violations  []domain.Violation
+		transaction *domain.Transaction
+		recordedAt  time.Time
+	}
+)
+
+func newIdempotencyKeys(retention time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		retention: retention,
+		results:   map[idempotencyKey]keyResult{},
+	}
+}
+
+// add keeps the result of the transaction with the key, then evicts the results past the retention.
+func (k *idempotencyKeys) add(accountID string, key string, result keyResult) {
+	id := idempotencyKey{accountID: accountID, key: key}
+	k.results[id] = result
+	k.order = append(k.order, id)
+
+	k.evict(result.recordedAt)
+}
+
+// evict drops the results recorded more than the retention before the given time.
+func (k *idempotencyKeys) evict(at time.Time) {
+	if k.retention <= 0 {
+		return
+	}
+
+	horizon := at.Add(-k.retention)
+	i := 0
+	for ; i < len(k.order); i++ {
+		result, ok := k.results[k.order[i]]
+		if ok && !result.recordedAt.Before(horizon) {
+			break
+		}
+		if ok {
+			delete(k.results, k.order[i])
+		}
+	}
+	k.order = k.order[i:]
+}
+
+// find returns the result of the transaction with the key, found tells whether there is one within the retention at the
+// given time.
+func (k *idempotencyKeys) find(accountID string, key string, at time.Time) (keyResult, bool) {
+	result, ok := k.results[idempotencyKey{accountID: accountID, key: key}]
+	if !ok || (k.retention > 0 && result.recordedAt.Before(at.Add(-k.retention))) {
+		return keyResult{}, false
+	}
+	return result, true
+}
+
+// matches tells whether the transaction is a retry of the recorded one: the same money as it was sent, before any
+// conversion, at the same merchant and category. Ids are not compared, since one missing is assigned from the key, nor
+// times, since on processing time a retry is made at the time it arrives.
+func (r keyResult) matches(transaction domain.Transaction) bool {
+	if r.transaction == nil {
+		return true
+	}
+	sent := r.transaction.Money
+	if r.transaction.Original != nil {
+		sent = *r.transaction.Original
+	}
+	return sent == transaction.Money && r.transaction.Merchant == transaction.Merchant &&
+		r.transaction.MCC == transaction.MCC
+}
+
+// errors returns the violations of the result as they were returned when recorded: the violation errors themselves
+// when they have no details to add, and the detailed violations wrapping them otherwise.
+func (r keyResult) errors() []error {
+	errs := []error{}
+	for _, violation := range r.violations {
+		if violation.Message == "" && len(violation.Metadata) == 0 {
+			errs = append(errs, violation.Unwrap())
+			continue
+		}
+		errs = append(errs, violation)
+	}
+	return errs
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenResult := keyResult{
+		account:    domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 80},
+		recordedAt: givenTime,
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should find result of key within retention": func(t *testing.T) {
+			// 	given
+			keys := newIdempotencyKeys(time.Hour)
+			keys.add("1", "k1", givenResult)
+
+			// 	when
+			result, found := keys.find("1", "k1", givenTime.Add(30*time.Minute))
+
+			// 	then
+			assert.True(t, found)
+			assert.Equal(t, givenResult, result)
+		},
+		"should not find result of key past retention": func(t *testing.T) {
+			// 	given
+			keys := newIdempotencyKeys(time.Hour)
+			keys.add("1", "k1", givenResult)
+
+			// 	when
+			_, found := keys.find("1", "k1", givenTime.Add(2*time.Hour))
+
+			// 	then
+			assert.False(t, found)
+		},
+		"should not find result of key of another account": func(t *testing.T) {
+			// 	given
+			keys := newIdempotencyKeys(time.Hour)
+			keys.add("1", "k1", givenResult)
+
+			// 	when
+			_, found := keys.find("2", "k1", givenTime)
+
+			// 	then
+			assert.False(t, found)
+		},
+		"should evict results past retention": func(t *testing.T) {
+			// 	given
+			keys := newIdempotencyKeys(time.Hour)
+			keys.add("1", "k1", givenResult)
+
+			// 	when
+			keys.add("1", "k2", keyResult{recordedAt: givenTime.Add(2 * time.Hour)})
+
+			// 	then
+			assert.Len(t, keys.results, 1)
+			assert.Len(t, keys.order, 1)
+		},
+		"should keep every result without retention": func(t *testing.T) {
+			// 	given
+			keys := newIdempotencyKeys(0)
+			keys.add("1", "k1", givenResult)
+
+			// 	when
+			_, found := keys.find("1", "k1", givenTime.Add(365*24*time.Hour))
+
+			// 	then
+			assert.True(t, found)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
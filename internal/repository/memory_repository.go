@@ -1,52 +1,698 @@
 package repository
 
 import (
-	"errors"
+	"sync"
 	"time"
 
 	"github.com/unknown/authorizer/internal/core/domain"
 )
 
+// MemoryRepository stores the account ledgers in memory, along with the accounts and transactions projected from them.
+// It is safe for concurrent use.
 type MemoryRepository struct {
-	transactions       []domain.Transaction
-	account            domain.Account
-	accountInitialized bool
+	mutex            *sync.RWMutex
+	clock            Clock
+	sequence         uint64
+	retention        time.Duration
+	historyRetention time.Duration
+	events           map[string][]domain.Event
+	transactions     map[string]*transactionIndex
+	declines         map[string]*transactionIndex
+	holds            map[string][]domain.Hold
+	spending         map[string]*spendIndex
+	merchantSpending map[string]map[string]*spendIndex
+	categorySpending map[string]map[string]*spendIndex
+	keys             *idempotencyKeys
+	decisions        map[string][]domain.Decision
+	accounts         map[string]domain.Account
 }
 
+// NewMemoryRepository returns the repository keeping every transaction indexed, every idempotency key and the whole
+// history, recording events at the system time.
 func NewMemoryRepository() MemoryRepository {
-	return MemoryRepository{}
+	return NewMemoryRepositoryWithRetention(0, 0, 0, systemClock{})
 }
 
-func (m *MemoryRepository) SaveTransaction(transaction domain.Transaction) {
-	m.transactions = append(m.transactions, transaction)
+// NewMemoryRepositoryWithRetention returns the repository keeping indexed only the transactions of an account within
+// the retention before its latest one, like the widest rule window. Older transactions are still found through the
+// ledger, only more slowly. Idempotency keys are kept for the key retention after they were recorded. The ledger of an
+// account keeps the events recorded within the history retention before its latest one, the older ones are trimmed
+// into the account they fold into, along with the decisions made and the holds settled by then. A zero retention keeps
+// everything. Events are recorded at the time the clock tells.
+func NewMemoryRepositoryWithRetention(
+	retention time.Duration,
+	keyRetention time.Duration,
+	historyRetention time.Duration,
+	clock Clock,
+) MemoryRepository {
+	return MemoryRepository{
+		mutex:            &sync.RWMutex{},
+		clock:            clock,
+		retention:        retention,
+		historyRetention: historyRetention,
+		events:           map[string][]domain.Event{},
+		transactions:     map[string]*transactionIndex{},
+		declines:         map[string]*transactionIndex{},
+		holds:            map[string][]domain.Hold{},
+		spending:         map[string]*spendIndex{},
+		merchantSpending: map[string]map[string]*spendIndex{},
+		categorySpending: map[string]map[string]*spendIndex{},
+		keys:             newIdempotencyKeys(keyRetention),
+		decisions:        map[string][]domain.Decision{},
+		accounts:         map[string]domain.Account{},
+	}
 }
 
-func (m *MemoryRepository) FindTransactionsAfter(time time.Time) []domain.Transaction {
-	foundTransactions := []domain.Transaction{}
-	for _, transaction := range m.transactions {
-		if transaction.CreatedAt.After(time) {
-			foundTransactions = append(foundTransactions, transaction)
+func (m *MemoryRepository) FindTransactionsAfter(accountID string, time time.Time) []domain.Transaction {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findTransactionsAfter(accountID, time)
+}
+
+// FindDeclinesAfter returns the transactions of the account rejected after the given time, in time order.
+func (m *MemoryRepository) FindDeclinesAfter(accountID string, time time.Time) []domain.Transaction {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findDeclinesAfter(accountID, time)
+}
+
+// CommitTransaction holds the repository lock while authorizing over the account, its transactions and declines after
+// the given time and its spending, so no other operation can change them between the checks and the commit. The
+// transaction is recorded as authorized when authorize returns no violations, and as rejected otherwise, followed by
+// the card change of the authorization. Holds expired by the transaction time are released first. A transaction with
+// the idempotency key of one already recorded is answered with the recorded result, without being authorized again,
+// unless it differs from the recorded one, and one with the id of a hold or transaction the account already has is not
+// authorized.
+func (m *MemoryRepository) CommitTransaction(
+	transaction domain.Transaction,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+) (domain.Account, []error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if result, found := m.findKeyResult(transaction); found {
+		if !result.matches(transaction) {
+			return result.account, nil, domain.ErrKeyReused
 		}
+		return result.account, result.errors(), nil
 	}
-	return foundTransactions
+
+	m.expireHolds(transaction.AccountID, transaction.CreatedAt)
+
+	account, ok := m.accounts[transaction.AccountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+	if m.idTaken(transaction.AccountID, transaction.ID) {
+		return account, nil, domain.ErrTransactionExists
+	}
+
+	authorization := authorize(account, m.findTransactionsAfter(transaction.AccountID, after), m.findDeclinesAfter(transaction.AccountID, after), m.spendingOf(transaction.AccountID))
+	errs := authorization.Violations
+	if len(errs) > 0 {
+		m.record(domain.NewTransactionRejected(authorization.Transaction, errs, m.clock.Now().UTC()))
+		if authorization.CardChange != nil {
+			m.record(domain.NewCardChanged(*authorization.CardChange, m.clock.Now().UTC()))
+		}
+		return m.accounts[transaction.AccountID], errs, nil
+	}
+
+	m.record(domain.NewTransactionAuthorized(authorization.Transaction, m.clock.Now().UTC()))
+
+	return m.accounts[transaction.AccountID], errs, nil
+}
+
+// SimulateTransaction authorizes the transaction like CommitTransaction but records nothing, returning the account as
+// it would be if the transaction were committed, with the holds expired by the transaction time released.
+func (m *MemoryRepository) SimulateTransaction(
+	transaction domain.Transaction,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization,
+) (domain.Account, []error, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	account, ok := m.accounts[transaction.AccountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+	if m.idTaken(transaction.AccountID, transaction.ID) {
+		return account, nil, domain.ErrTransactionExists
+	}
+
+	expiredHolds := m.findExpiredHolds(transaction.AccountID, transaction.CreatedAt)
+	for _, hold := range expiredHolds {
+		account = account.Apply(domain.NewHoldExpired(hold, m.clock.Now().UTC()))
+	}
+	spending := releasedSpending{Spending: m.spendingOf(transaction.AccountID), holds: expiredHolds}
+
+	authorization := authorize(account, m.findTransactionsAfter(transaction.AccountID, after), m.findDeclinesAfter(transaction.AccountID, after), spending)
+	errs := authorization.Violations
+	if len(errs) > 0 {
+		if authorization.CardChange != nil {
+			account = account.Apply(domain.NewCardChanged(*authorization.CardChange, m.clock.Now().UTC()))
+		}
+		return account, errs, nil
+	}
+
+	return account.Apply(domain.NewTransactionAuthorized(authorization.Transaction, m.clock.Now().UTC())), errs, nil
+}
+
+// CommitReversal holds the repository lock while validating the reversal over the account and the authorized
+// transaction it references, found tells whether there is one. The reversal is recorded when validate returns no
+// violations, after the holds expired by the reversal time, so a rejected reversal expires no hold.
+func (m *MemoryRepository) CommitReversal(
+	reversal domain.Reversal,
+	validate func(account domain.Account, transaction domain.Transaction, found bool) []error,
+) (domain.Account, []error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account, ok := m.accounts[reversal.AccountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+
+	transaction, found := m.findTransaction(reversal.AccountID, reversal.TransactionID)
+	if errs := validate(account, transaction, found); len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	m.expireHolds(reversal.AccountID, reversal.CreatedAt)
+	m.record(domain.NewTransactionReversed(transaction, reversal, m.clock.Now().UTC()))
+
+	return m.accounts[reversal.AccountID], []error{}, nil
+}
+
+// SpentBetween returns the amount the account spent from the given time, inclusive, to the other, exclusive. Authorized
+// transactions and captures count as spent, and holds while they are held.
+func (m *MemoryRepository) SpentBetween(accountID string, from time.Time, to time.Time) int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.spending[accountID].sum(from, to)
+}
+
+// FindTransaction returns the authorized transaction with the given id, found tells whether there is one.
+func (m *MemoryRepository) FindTransaction(accountID string, transactionID string) (domain.Transaction, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findTransaction(accountID, transactionID)
+}
+
+// CommitHold holds the repository lock while authorizing the hold like a transaction, recording the hold authorize
+// returns as placed when there are no violations. A hold with the id of a hold or transaction the account already has
+// is not authorized.
+func (m *MemoryRepository) CommitHold(
+	hold domain.Hold,
+	after time.Time,
+	authorize func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) (domain.Hold, []error),
+) (domain.Account, []error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expireHolds(hold.AccountID, hold.CreatedAt)
+
+	account, ok := m.accounts[hold.AccountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+	if m.idTaken(hold.AccountID, hold.ID) {
+		return account, nil, domain.ErrHoldExists
+	}
+
+	placed, errs := authorize(account, m.findTransactionsAfter(hold.AccountID, after), m.findDeclinesAfter(hold.AccountID, after), m.spendingOf(hold.AccountID))
+	if len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	m.record(domain.NewHoldPlaced(placed, m.clock.Now().UTC()))
+
+	return m.accounts[hold.AccountID], []error{}, nil
+}
+
+// CommitCapture holds the repository lock while validating the capture over the account and the hold it references,
+// found tells whether there is one. The capture validate returns is recorded when there are no violations.
+func (m *MemoryRepository) CommitCapture(
+	capture domain.Capture,
+	validate func(account domain.Account, hold domain.Hold, found bool) (domain.Capture, []error),
+) (domain.Account, []error, error) {
+	return m.commitSettlement(capture.AccountID, capture.HoldID, capture.CreatedAt, func(account domain.Account, hold domain.Hold, found bool) (domain.Event, []error) {
+		captured, errs := validate(account, hold, found)
+		return domain.NewHoldCaptured(hold, captured, m.clock.Now().UTC()), errs
+	})
+}
+
+// CommitRelease holds the repository lock while validating the release over the account and the hold it references,
+// found tells whether there is one. The release is recorded when validate returns no violations.
+func (m *MemoryRepository) CommitRelease(
+	release domain.Release,
+	validate func(account domain.Account, hold domain.Hold, found bool) []error,
+) (domain.Account, []error, error) {
+	return m.commitSettlement(release.AccountID, release.HoldID, release.CreatedAt, func(account domain.Account, hold domain.Hold, found bool) (domain.Event, []error) {
+		return domain.NewHoldReleased(hold, release, m.clock.Now().UTC()), validate(account, hold, found)
+	})
+}
+
+// FindHold returns the hold with the given id, found tells whether there is one.
+func (m *MemoryRepository) FindHold(accountID string, holdID string) (domain.Hold, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findHold(accountID, holdID)
+}
+
+// FindExpiredHolds returns the holds of the account still held past their expiration at the given time.
+func (m *MemoryRepository) FindExpiredHolds(accountID string, at time.Time) []domain.Hold {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findExpiredHolds(accountID, at)
 }
 
 func (m *MemoryRepository) SaveAccount(account domain.Account) (domain.Account, error) {
-	if !m.accountInitialized {
-		m.account = account
-		m.accountInitialized = true
-		return m.account, nil
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if savedAccount, ok := m.accounts[account.ID]; ok {
+		return savedAccount, domain.ErrAccountExists
 	}
-	return m.account, errors.New("account already initialized")
+	m.record(domain.NewAccountCreated(account, m.clock.Now().UTC()))
+	return account, nil
 }
 
-func (m *MemoryRepository) FindAccount() (domain.Account, error) {
-	if !m.accountInitialized {
-		return domain.Account{}, errors.New("account not initialized")
+func (m *MemoryRepository) FindAccount(accountID string) (domain.Account, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	account, ok := m.accounts[accountID]
+	if !ok {
+		return domain.Account{}, domain.ErrAccountNotFound
 	}
-	return m.account, nil
+	return account, nil
 }
 
-func (m *MemoryRepository) UpdateAccountLimit(newAvailableLimit int) {
-	m.account.AvailableLimit = newAvailableLimit
+// CommitCardChange holds the repository lock while validating the card change over the account, recording it when
+// validate returns no violations.
+func (m *MemoryRepository) CommitCardChange(
+	change domain.CardChange,
+	validate func(domain.Account) []error,
+) (domain.Account, []error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account, ok := m.accounts[change.AccountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+
+	if errs := validate(account); len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	m.record(domain.NewCardChanged(change, m.clock.Now().UTC()))
+
+	return m.accounts[change.AccountID], []error{}, nil
+}
+
+// CommitLimitChange holds the repository lock while validating the limit change over the account, recording it when
+// validate returns no violations.
+func (m *MemoryRepository) CommitLimitChange(
+	change domain.LimitChange,
+	validate func(domain.Account) []error,
+) (domain.Account, []error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account, ok := m.accounts[change.AccountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+
+	if errs := validate(account); len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	m.record(domain.NewTotalLimitChanged(change, m.clock.Now().UTC()))
+
+	return m.accounts[change.AccountID], []error{}, nil
+}
+
+func (m *MemoryRepository) FindEvents(accountID string) []domain.Event {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return append([]domain.Event{}, m.events[accountID]...)
+}
+
+// FindDecisions returns the decisions on the transactions of the account matching the filter, in the order they were
+// made. Decisions are kept for every transaction in the ledger, whatever the retention of the transaction index.
+func (m *MemoryRepository) FindDecisions(accountID string, filter domain.DecisionFilter) ([]domain.Decision, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, ok := m.accounts[accountID]; !ok {
+		return nil, domain.ErrAccountNotFound
+	}
+
+	decisions := []domain.Decision{}
+	for _, decision := range m.decisions[accountID] {
+		if filter.Matches(decision) {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions, nil
+}
+
+func (m *MemoryRepository) commitSettlement(
+	accountID string,
+	holdID string,
+	at time.Time,
+	settle func(account domain.Account, hold domain.Hold, found bool) (domain.Event, []error),
+) (domain.Account, []error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expireHolds(accountID, at)
+
+	account, ok := m.accounts[accountID]
+	if !ok {
+		return domain.Account{}, nil, domain.ErrAccountNotFound
+	}
+
+	hold, found := m.findHold(accountID, holdID)
+	event, errs := settle(account, hold, found)
+	if len(errs) > 0 {
+		return account, errs, nil
+	}
+
+	m.record(event)
+
+	return m.accounts[accountID], []error{}, nil
+}
+
+// expireHolds records the expiration of the holds of the account past their expiration at the given time, it must be
+// called holding the lock.
+func (m *MemoryRepository) expireHolds(accountID string, at time.Time) {
+	for _, hold := range m.findExpiredHolds(accountID, at) {
+		m.record(domain.NewHoldExpired(hold, m.clock.Now().UTC()))
+	}
+}
+
+// record appends the event to the ledger with the next sequence, it must be called holding the lock.
+func (m *MemoryRepository) record(event domain.Event) {
+	event.Sequence = m.sequence + 1
+	m.apply(event)
+}
+
+// apply appends the event to the ledger and projects it, it must be called holding the lock.
+func (m *MemoryRepository) apply(event domain.Event) {
+	m.events[event.AccountID] = append(m.events[event.AccountID], event)
+	m.accounts[event.AccountID] = m.accounts[event.AccountID].Apply(event)
+	switch event.Type {
+	case domain.EventTransactionAuthorized:
+		m.indexTransaction(*event.Transaction, event.RecordedAt)
+		m.spend(event.AccountID, *event.Transaction, event.Transaction.Amount, event.RecordedAt)
+		m.keepKeyResult(event)
+		m.decide(event)
+	case domain.EventTransactionRejected:
+		m.indexDecline(*event.Transaction, event.RecordedAt)
+		m.keepKeyResult(event)
+		m.decide(event)
+	case domain.EventHoldPlaced:
+		m.holds[event.AccountID] = append(m.holds[event.AccountID], *event.Hold)
+		m.spend(event.AccountID, event.Hold.Transaction(), event.Hold.Amount, event.RecordedAt)
+	case domain.EventHoldCaptured:
+		m.setHoldStatus(event.AccountID, event.Hold.ID, domain.HoldCaptured)
+		m.indexTransaction(*event.Transaction, event.RecordedAt)
+		m.spend(event.AccountID, event.Hold.Transaction(), -event.Hold.Amount, event.RecordedAt)
+		m.spend(event.AccountID, *event.Transaction, event.Transaction.Amount, event.RecordedAt)
+	case domain.EventHoldReleased:
+		m.setHoldStatus(event.AccountID, event.Hold.ID, domain.HoldReleased)
+		m.spend(event.AccountID, event.Hold.Transaction(), -event.Hold.Amount, event.RecordedAt)
+	case domain.EventHoldExpired:
+		m.setHoldStatus(event.AccountID, event.Hold.ID, domain.HoldExpired)
+		m.spend(event.AccountID, event.Hold.Transaction(), -event.Hold.Amount, event.RecordedAt)
+	case domain.EventTransactionReversed:
+		if index, ok := m.transactions[event.AccountID]; ok {
+			if i, found := index.find(event.Transaction.ID); found {
+				index.transactions[i].Reversed = true
+			}
+		}
+		m.spend(event.AccountID, *event.Transaction, -event.Transaction.Amount, event.RecordedAt)
+	}
+	m.trimHistory(event.AccountID, event.RecordedAt)
+	m.sequence = event.Sequence
+}
+
+// trimHistory replaces the events of the account recorded longer than the history retention before the given time with
+// the event of the account they fold into, then forgets the decisions made and the holds settled by then. It must be
+// called holding the lock.
+func (m *MemoryRepository) trimHistory(accountID string, at time.Time) {
+	if m.historyRetention <= 0 {
+		return
+	}
+
+	horizon := at.Add(-m.historyRetention)
+	events := m.events[accountID]
+	i := 0
+	for i < len(events) && events[i].RecordedAt.Before(horizon) {
+		i++
+	}
+	if i == 0 || (i == 1 && events[0].Type == domain.EventHistoryTrimmed) {
+		return
+	}
+
+	for _, event := range events[:i] {
+		switch event.Type {
+		case domain.EventHoldCaptured, domain.EventHoldReleased, domain.EventHoldExpired:
+			m.forgetHold(accountID, event.Hold.ID)
+		}
+	}
+	last := events[i-1]
+	events[i-1] = domain.NewHistoryTrimmed(domain.Fold(events[:i]), last.Sequence, last.RecordedAt)
+	m.events[accountID] = events[i-1:]
+
+	decisions := m.decisions[accountID]
+	j := 0
+	for j < len(decisions) && decisions[j].DecidedAt.Before(horizon) {
+		j++
+	}
+	m.decisions[accountID] = decisions[j:]
+}
+
+// indexTransaction adds the transaction recorded at the given time to the index of its account, it must be called
+// holding the lock.
+func (m *MemoryRepository) indexTransaction(transaction domain.Transaction, recordedAt time.Time) {
+	index, ok := m.transactions[transaction.AccountID]
+	if !ok {
+		index = newTransactionIndex(m.retention)
+		m.transactions[transaction.AccountID] = index
+	}
+	index.add(transaction, recordedAt)
+}
+
+// indexDecline adds the rejected transaction recorded at the given time to the declines index of its account, it must
+// be called holding the lock.
+func (m *MemoryRepository) indexDecline(transaction domain.Transaction, recordedAt time.Time) {
+	index, ok := m.declines[transaction.AccountID]
+	if !ok {
+		index = newTransactionIndex(m.retention)
+		m.declines[transaction.AccountID] = index
+	}
+	index.add(transaction, recordedAt)
+}
+
+// keepKeyResult keeps the result of the transaction of the event when it has an idempotency key, it must be called
+// holding the lock after the event is projected.
+func (m *MemoryRepository) keepKeyResult(event domain.Event) {
+	if event.Transaction.IdempotencyKey == "" {
+		return
+	}
+	m.keys.add(event.AccountID, event.Transaction.IdempotencyKey, keyResult{
+		account:     m.accounts[event.AccountID],
+		violations:  event.ViolationDetails,
+		transaction: event.Transaction,
+		recordedAt:  event.RecordedAt,
+	})
+}
+
+// decide keeps the decision recorded by the transaction event, it must be called holding the lock after the event is
+// projected.
+func (m *MemoryRepository) decide(event domain.Event) {
+	m.decisions[event.AccountID] = append(m.decisions[event.AccountID], domain.NewDecision(event, m.accounts[event.AccountID]))
+}
+
+// findKeyResult returns the recorded result of the transaction with the idempotency key of the given one, it must be
+// called holding the lock.
+func (m *MemoryRepository) findKeyResult(transaction domain.Transaction) (keyResult, bool) {
+	if transaction.IdempotencyKey == "" {
+		return keyResult{}, false
+	}
+	return m.keys.find(transaction.AccountID, transaction.IdempotencyKey, m.clock.Now().UTC())
+}
+
+// spend adds the amount recorded at the given time to the spending of the account at the time of the transaction it was
+// spent on, as well as to its spending at the merchant and category of the transaction. It must be called holding the
+// lock.
+func (m *MemoryRepository) spend(accountID string, spent domain.Transaction, amount int, recordedAt time.Time) {
+	addSpend(m.spending, accountID, spent.CreatedAt, amount, recordedAt)
+	if spent.Merchant != "" {
+		if m.merchantSpending[accountID] == nil {
+			m.merchantSpending[accountID] = map[string]*spendIndex{}
+		}
+		addSpend(m.merchantSpending[accountID], spent.Merchant, spent.CreatedAt, amount, recordedAt)
+	}
+	if spent.MCC != "" {
+		if m.categorySpending[accountID] == nil {
+			m.categorySpending[accountID] = map[string]*spendIndex{}
+		}
+		addSpend(m.categorySpending[accountID], spent.MCC, spent.CreatedAt, amount, recordedAt)
+	}
+}
+
+// addSpend adds the amount recorded at the given time to the spend index with the key, at the other.
+func addSpend(indexes map[string]*spendIndex, key string, at time.Time, amount int, recordedAt time.Time) {
+	index, ok := indexes[key]
+	if !ok {
+		index = &spendIndex{}
+		indexes[key] = index
+	}
+	index.add(at, amount, recordedAt)
+}
+
+// spendingOf returns the spending of the account, it must be read holding the lock.
+func (m *MemoryRepository) spendingOf(accountID string) domain.Spending {
+	return accountSpending{
+		index:      m.spending[accountID],
+		merchants:  m.merchantSpending[accountID],
+		categories: m.categorySpending[accountID],
+	}
+}
+
+// applyEvents appends events that already have their sequence, like the ones replayed from disk.
+func (m *MemoryRepository) applyEvents(events ...domain.Event) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, event := range events {
+		m.apply(event)
+	}
+}
+
+// findTransaction looks the transaction up in the index of the account, and in its ledger when it may have been
+// evicted.
+func (m *MemoryRepository) findTransaction(accountID string, transactionID string) (domain.Transaction, bool) {
+	index, ok := m.transactions[accountID]
+	if transactionID == "" || !ok {
+		return domain.Transaction{}, false
+	}
+	if i, found := index.find(transactionID); found {
+		return index.transactions[i], true
+	}
+	if index.complete() {
+		return domain.Transaction{}, false
+	}
+
+	for _, transaction := range ledgerTransactions(m.events[accountID]) {
+		if transaction.ID == transactionID {
+			return transaction, true
+		}
+	}
+	return domain.Transaction{}, false
+}
+
+// idTaken tells whether the account has a hold or authorized transaction with the given id, a hold being captured as
+// a transaction with its id. It must be called holding the lock.
+func (m *MemoryRepository) idTaken(accountID string, id string) bool {
+	if _, found := m.findHold(accountID, id); found {
+		return true
+	}
+	_, found := m.findTransaction(accountID, id)
+	return found
+}
+
+func (m *MemoryRepository) setHoldStatus(accountID string, holdID string, status domain.HoldStatus) {
+	for i, hold := range m.holds[accountID] {
+		if hold.ID == holdID {
+			m.holds[accountID][i].Status = status
+			return
+		}
+	}
+}
+
+// forgetHold drops the hold with the given id from the holds of the account, it must be called holding the lock.
+func (m *MemoryRepository) forgetHold(accountID string, holdID string) {
+	for i, hold := range m.holds[accountID] {
+		if hold.ID == holdID {
+			m.holds[accountID] = append(m.holds[accountID][:i], m.holds[accountID][i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *MemoryRepository) findHold(accountID string, holdID string) (domain.Hold, bool) {
+	if holdID == "" {
+		return domain.Hold{}, false
+	}
+	for _, hold := range m.holds[accountID] {
+		if hold.ID == holdID {
+			return hold, true
+		}
+	}
+	return domain.Hold{}, false
+}
+
+func (m *MemoryRepository) findExpiredHolds(accountID string, at time.Time) []domain.Hold {
+	expiredHolds := []domain.Hold{}
+	for _, hold := range m.holds[accountID] {
+		if hold.ExpiredAt(at) {
+			expiredHolds = append(expiredHolds, hold)
+		}
+	}
+	return expiredHolds
+}
+
+// findDeclinesAfter returns the rejected transactions of the account made after the given time, going back to the
+// ledger when some may have been evicted from the index, it must be called holding the lock.
+func (m *MemoryRepository) findDeclinesAfter(accountID string, after time.Time) []domain.Transaction {
+	index, ok := m.declines[accountID]
+	if !ok {
+		return []domain.Transaction{}
+	}
+	if declines, complete := index.after(after); complete {
+		return declines
+	}
+
+	foundDeclines := []domain.Transaction{}
+	for _, decline := range ledgerDeclines(m.events[accountID]) {
+		if decline.CreatedAt.After(after) {
+			foundDeclines = append(foundDeclines, decline)
+		}
+	}
+	return foundDeclines
+}
+
+// findTransactionsAfter returns the transactions of the account after the given time in time order, from its index or,
+// when some of them were evicted, from its ledger.
+func (m *MemoryRepository) findTransactionsAfter(accountID string, after time.Time) []domain.Transaction {
+	index, ok := m.transactions[accountID]
+	if !ok {
+		return []domain.Transaction{}
+	}
+	if transactions, complete := index.after(after); complete {
+		return transactions
+	}
+
+	foundTransactions := []domain.Transaction{}
+	for _, transaction := range ledgerTransactions(m.events[accountID]) {
+		if transaction.CreatedAt.After(after) {
+			foundTransactions = append(foundTransactions, transaction)
+		}
+	}
+	return foundTransactions
 }
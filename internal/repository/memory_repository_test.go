@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -8,99 +12,105 @@ import (
 	"github.com/unknown/authorizer/internal/core/domain"
 )
 
-func TestSaveTransaction(t *testing.T) {
+func TestFindTransactionsAfter(t *testing.T) {
 	testCases := map[string]func(*testing.T){
-		"should save one transaction with success": func(t *testing.T) {
+		"should return found transactions after given time": func(t *testing.T) {
 			// 	given
-			givenTransaction := domain.Transaction{
-				Amount:    100,
-				Merchant:  "ifood",
-				CreatedAt: time.Now().UTC(),
+			givenTransactions := []domain.Transaction{
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
+				{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 75}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{AccountID: "1", Merchant: "mercado", Money: domain.Money{Amount: 200}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
 			}
 
 			repository := NewMemoryRepository()
 
+			saveTransactions(&repository, givenTransactions...)
+
 			// 	when
-			repository.SaveTransaction(givenTransaction)
+			givenTime := time.Now().UTC().Add(-2 * time.Minute)
+			foundTransactions := repository.FindTransactionsAfter("1", givenTime)
 
 			// 	then
-			wantTransactions := []domain.Transaction{givenTransaction}
-			assert.ElementsMatch(t, repository.transactions, wantTransactions)
+			wantTransactions := []domain.Transaction{
+				givenTransactions[1],
+				givenTransactions[2],
+			}
+			assert.ElementsMatch(t, wantTransactions, foundTransactions)
 		},
-		"should save multiple transactions with success": func(t *testing.T) {
+		"should return empty transactions not found after given time": func(t *testing.T) {
 			// 	given
 			givenTransactions := []domain.Transaction{
-				{Merchant: "ifood", Amount: 100, CreatedAt: time.Now().UTC()},
-				{Merchant: "uber-eats", Amount: 75, CreatedAt: time.Now().UTC()},
-				{Merchant: "mercado", Amount: 200, CreatedAt: time.Now().UTC()},
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
+				{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 75}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
+				{AccountID: "1", Merchant: "mercado", Money: domain.Money{Amount: 200}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
 			}
 
 			repository := NewMemoryRepository()
 
+			saveTransactions(&repository, givenTransactions...)
+
 			// 	when
-			repository.SaveTransaction(givenTransactions[0])
-			repository.SaveTransaction(givenTransactions[1])
-			repository.SaveTransaction(givenTransactions[2])
+			givenTime := time.Now().UTC().Add(-2 * time.Minute)
+			foundTransactions := repository.FindTransactionsAfter("1", givenTime)
 
 			// 	then
-			assert.ElementsMatch(t, repository.transactions, givenTransactions)
+			assert.Empty(t, foundTransactions)
 		},
-	}
-
-	for name, run := range testCases {
-		t.Run(name, func(t *testing.T) {
-			run(t)
-		})
-	}
-}
-
-func TestFindTransactionsAfter(t *testing.T) {
-	testCases := map[string]func(*testing.T){
-		"should return found transactions after given time": func(t *testing.T) {
+		"should return only transactions of given account": func(t *testing.T) {
 			// 	given
 			givenTransactions := []domain.Transaction{
-				{Merchant: "ifood", Amount: 100, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
-				{Merchant: "uber-eats", Amount: 75, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
-				{Merchant: "mercado", Amount: 200, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{AccountID: "2", Merchant: "uber-eats", Money: domain.Money{Amount: 75}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
 			}
 
 			repository := NewMemoryRepository()
 
-			repository.SaveTransaction(givenTransactions[0])
-			repository.SaveTransaction(givenTransactions[1])
-			repository.SaveTransaction(givenTransactions[2])
+			saveTransactions(&repository, givenTransactions...)
 
 			// 	when
 			givenTime := time.Now().UTC().Add(-2 * time.Minute)
-			foundTransactions := repository.FindTransactionsAfter(givenTime)
+			foundTransactions := repository.FindTransactionsAfter("2", givenTime)
 
 			// 	then
-			wantTransactions := []domain.Transaction{
-				givenTransactions[1],
-				givenTransactions[2],
-			}
+			wantTransactions := []domain.Transaction{givenTransactions[1]}
 			assert.ElementsMatch(t, wantTransactions, foundTransactions)
 		},
-		"should return empty transactions not found after given time": func(t *testing.T) {
+		"should return transactions in time order": func(t *testing.T) {
 			// 	given
 			givenTransactions := []domain.Transaction{
-				{Merchant: "ifood", Amount: 100, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
-				{Merchant: "uber-eats", Amount: 75, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
-				{Merchant: "mercado", Amount: 200, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+				{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 75}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
 			}
 
 			repository := NewMemoryRepository()
 
-			repository.SaveTransaction(givenTransactions[0])
-			repository.SaveTransaction(givenTransactions[1])
-			repository.SaveTransaction(givenTransactions[2])
+			saveTransactions(&repository, givenTransactions...)
 
 			// 	when
-			givenTime := time.Now().UTC().Add(-2 * time.Minute)
-			foundTransactions := repository.FindTransactionsAfter(givenTime)
+			foundTransactions := repository.FindTransactionsAfter("1", time.Time{})
 
 			// 	then
-			assert.Empty(t, foundTransactions)
+			assert.Equal(t, []domain.Transaction{givenTransactions[1], givenTransactions[0]}, foundTransactions)
+		},
+		"should return transactions past the retention from the ledger": func(t *testing.T) {
+			// 	given
+			givenTransactions := []domain.Transaction{
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC().Add(-3 * time.Hour)},
+				{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 75}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+			}
+
+			repository := NewMemoryRepositoryWithRetention(time.Hour, 0, 0, systemClock{})
+
+			saveTransactions(&repository, givenTransactions...)
+
+			// 	when
+			recentTransactions := repository.FindTransactionsAfter("1", time.Now().UTC().Add(-2*time.Minute))
+			allTransactions := repository.FindTransactionsAfter("1", time.Time{})
+
+			// 	then
+			assert.Len(t, repository.transactions["1"].transactions, 1)
+			assert.Equal(t, []domain.Transaction{givenTransactions[1]}, recentTransactions)
+			assert.Equal(t, givenTransactions, allTransactions)
 		},
 	}
 
@@ -116,6 +126,7 @@ func TestSaveAccount(t *testing.T) {
 		"should save account with success": func(t *testing.T) {
 			// 	given
 			givenAccount := domain.Account{
+				ID:             "1",
 				ActiveCard:     false,
 				AvailableLimit: 100,
 			}
@@ -131,22 +142,39 @@ func TestSaveAccount(t *testing.T) {
 		"should return error when account already initialized": func(t *testing.T) {
 			// 	given
 			wantAccount := domain.Account{
+				ID:             "1",
 				ActiveCard:     false,
 				AvailableLimit: 100,
 			}
 
 			repository := NewMemoryRepository()
 
-			firstAccount, err := repository.SaveAccount(domain.Account{ActiveCard: false, AvailableLimit: 100})
+			firstAccount, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100})
 			assert.Equal(t, wantAccount, firstAccount)
 			assert.NoError(t, err)
 
 			// 	when
-			secondAccount, err := repository.SaveAccount(domain.Account{ActiveCard: true, AvailableLimit: 300})
+			secondAccount, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 300})
 
 			// 	then
 			assert.Equal(t, wantAccount, secondAccount)
-			assert.EqualError(t, err, "account already initialized")
+			assert.ErrorIs(t, err, domain.ErrAccountExists)
+		},
+		"should save accounts with different ids": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+
+			firstAccount, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100})
+			assert.NotEmpty(t, firstAccount)
+			assert.NoError(t, err)
+
+			// 	when
+			givenAccount := domain.Account{ID: "2", ActiveCard: true, AvailableLimit: 300}
+			secondAccount, err := repository.SaveAccount(givenAccount)
+
+			// 	then
+			assert.Equal(t, givenAccount, secondAccount)
+			assert.NoError(t, err)
 		},
 	}
 
@@ -162,12 +190,12 @@ func TestFindAccount(t *testing.T) {
 		"should find account with success": func(t *testing.T) {
 			// 	given
 			repository := NewMemoryRepository()
-			savedAccount, err := repository.SaveAccount(domain.Account{ActiveCard: false, AvailableLimit: 100})
+			savedAccount, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: false, AvailableLimit: 100})
 			assert.NoError(t, err)
 			assert.NotEmpty(t, savedAccount)
 
 			// 	when
-			foundAccount, err := repository.FindAccount()
+			foundAccount, err := repository.FindAccount("1")
 
 			// 	then
 			assert.Equal(t, savedAccount, foundAccount)
@@ -178,11 +206,11 @@ func TestFindAccount(t *testing.T) {
 			repository := NewMemoryRepository()
 
 			// 	when
-			foundAccount, err := repository.FindAccount()
+			foundAccount, err := repository.FindAccount("1")
 
 			// 	then
 			assert.Empty(t, foundAccount)
-			assert.EqualError(t, err, "account not initialized")
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
 		},
 	}
 
@@ -193,22 +221,371 @@ func TestFindAccount(t *testing.T) {
 	}
 }
 
-func TestUpdateAccountLimit(t *testing.T) {
+func TestCommitTransaction(t *testing.T) {
+	authorize := func(transaction domain.Transaction) func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization {
+		return func(account domain.Account, _ []domain.Transaction, _ []domain.Transaction, _ domain.Spending) domain.Authorization {
+			if account.AvailableLimit < transaction.Amount {
+				return domain.Authorization{Transaction: transaction, Violations: []error{domain.ErrInsufficientLimit}}
+			}
+			return domain.Authorization{Transaction: transaction}
+		}
+	}
+
 	testCases := map[string]func(*testing.T){
-		"should update account limit with success": func(t *testing.T) {
+		"should record authorized transaction and debit account": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Equal(t, []domain.Transaction{givenTransaction}, repository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should return error when account already has transaction or hold with id": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{ID: "t1", AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Merchant: "hotel", Money: domain.Money{Amount: 10}, CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			givenHoldTransaction := givenHold.Transaction()
+			_, _, holdErr := repository.CommitTransaction(givenHoldTransaction, time.Time{}, authorize(givenHoldTransaction))
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrTransactionExists)
+			assert.ErrorIs(t, holdErr, domain.ErrTransactionExists)
+			assert.Empty(t, errs)
+			assert.Equal(t, 65, account.AvailableLimit)
+			assert.Len(t, repository.FindTransactionsAfter("1", time.Time{}), 1)
+		},
+		"should record card change of rejected transaction along with the rejection": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: time.Now().UTC()}
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardBlock, Reason: "too-many-declines", CreatedAt: givenTransaction.CreatedAt}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, lockAs(givenTransaction, givenChange, domain.ErrTooManyDeclines))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrTooManyDeclines}, errs)
+			assert.Equal(t, domain.CardBlocked, account.CardStatus())
+			events := repository.FindEvents("1")
+			assert.Len(t, events, 3)
+			assert.Equal(t, domain.EventTransactionRejected, events[1].Type)
+			assert.Equal(t, &givenChange, events[2].CardChange)
+		},
+		"should give transactions after given time to authorize": func(t *testing.T) {
+			// 	given
+			givenTransactions := []domain.Transaction{
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 100}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
+				{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 75}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+			}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			saveTransactions(&repository, givenTransactions...)
+
+			// 	when
+			givenTransaction := domain.Transaction{AccountID: "1", CreatedAt: time.Now().UTC()}
+			var authorizedTransactions []domain.Transaction
+			_, _, err = repository.CommitTransaction(
+				givenTransaction,
+				time.Now().UTC().Add(-2*time.Minute),
+				func(account domain.Account, transactions []domain.Transaction, _ []domain.Transaction, _ domain.Spending) domain.Authorization {
+					authorizedTransactions = transactions
+					return domain.Authorization{Transaction: givenTransaction}
+				},
+			)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Transaction{givenTransactions[1]}, authorizedTransactions)
+		},
+		"should give declined transactions after given time to authorize": func(t *testing.T) {
+			// 	given
+			givenDeclines := []domain.Transaction{
+				{AccountID: "1", Merchant: "casino", Money: domain.Money{Amount: 900}, CreatedAt: time.Now().UTC().Add(-3 * time.Minute)},
+				{AccountID: "1", Merchant: "casino", Money: domain.Money{Amount: 800}, CreatedAt: time.Now().UTC().Add(-1 * time.Minute)},
+			}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			for _, decline := range givenDeclines {
+				_, _, err = repository.CommitTransaction(decline, time.Time{}, authorize(decline))
+				assert.NoError(t, err)
+			}
+
+			// 	when
+			givenTransaction := domain.Transaction{AccountID: "1", CreatedAt: time.Now().UTC()}
+			var authorizedDeclines []domain.Transaction
+			_, _, err = repository.CommitTransaction(
+				givenTransaction,
+				time.Now().UTC().Add(-2*time.Minute),
+				func(_ domain.Account, _ []domain.Transaction, declines []domain.Transaction, _ domain.Spending) domain.Authorization {
+					authorizedDeclines = declines
+					return domain.Authorization{Transaction: givenTransaction}
+				},
+			)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Transaction{givenDeclines[1]}, authorizedDeclines)
+			assert.Equal(t, givenDeclines, repository.FindDeclinesAfter("1", time.Time{}))
+		},
+		"should record rejected transaction when authorize returns violations": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 250}, CreatedAt: time.Now().UTC()}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrInsufficientLimit}, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.Empty(t, repository.FindTransactionsAfter("1", time.Time{}))
+
+			events := repository.FindEvents("1")
+			assert.Len(t, events, 2)
+			assert.Equal(t, domain.EventTransactionRejected, events[1].Type)
+			assert.Equal(t, []string{"insufficient-limit"}, events[1].Violations)
+			assert.Equal(t, &givenTransaction, events[1].Transaction)
+		},
+		"should return recorded result of transaction retried with idempotency key": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{
+				AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC(),
+			}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Len(t, repository.FindTransactionsAfter("1", time.Time{}), 1)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should return recorded violations of rejected transaction retried with idempotency key": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{
+				AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 250}, CreatedAt: time.Now().UTC(),
+			}
+			givenErrs := []error{
+				domain.NewViolation(domain.ErrInsufficientLimit, "amount exceeds the available limit", map[string]interface{}{
+					"available": 100,
+					"requested": 250,
+				}),
+				domain.ErrDoubleTransaction,
+			}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction, givenErrs...))
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, givenErrs, errs)
+			assert.True(t, errors.Is(errs[0], domain.ErrInsufficientLimit))
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should return error when idempotency key is retried with another transaction": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{
+				AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC(),
+			}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			assert.NoError(t, err)
+
+			// 	when
+			givenOtherTransaction := givenTransaction
+			givenOtherTransaction.Amount = 30
+			account, errs, err := repository.CommitTransaction(givenOtherTransaction, time.Time{}, authorize(givenOtherTransaction))
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrKeyReused)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should return recorded result of converted transaction retried with idempotency key": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{
+				ID: "t1", AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 20, Currency: "EUR"},
+				CreatedAt: time.Now().UTC(),
+			}
+			givenConverted := givenTransaction
+			givenConverted.Money = domain.Money{Amount: 25, Currency: "USD"}
+			givenConverted.Original = &givenTransaction.Money
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, Currency: "USD"})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenConverted))
+			assert.NoError(t, err)
+
+			// 	when
+			givenRetry := givenTransaction
+			givenRetry.ID = "k1"
+			givenRetry.CreatedAt = givenTransaction.CreatedAt.Add(time.Minute)
+			account, errs, err := repository.CommitTransaction(givenRetry, time.Time{}, authorize(givenRetry))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should authorize transaction with idempotency key recorded for another account": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			for _, id := range []string{"1", "2"} {
+				_, err := repository.SaveAccount(domain.Account{ID: id, ActiveCard: true, AvailableLimit: 100})
+				assert.NoError(t, err)
+			}
+			givenTransaction := domain.Transaction{
+				AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC(),
+			}
+			_, _, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			assert.NoError(t, err)
+
+			// 	when
+			givenTransaction.AccountID = "2"
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+		},
+		"should answer transaction with recorded result while its idempotency key is within the retention": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{
+				AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC(),
+			}
+			givenClock := &fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+
+			repository := NewMemoryRepositoryWithRetention(0, time.Hour, 0, givenClock)
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			assert.NoError(t, err)
+			givenClock.advance(time.Hour)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			assert.Equal(t, givenClock.now.Add(-time.Hour), repository.FindEvents("1")[1].RecordedAt)
+		},
+		"should authorize transaction again once its idempotency key is past the retention": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{
+				AccountID: "1", IdempotencyKey: "k1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC(),
+			}
+
+			givenClock := &fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+
+			repository := NewMemoryRepositoryWithRetention(0, time.Hour, 0, givenClock)
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+			assert.NoError(t, err)
+			givenClock.advance(time.Hour + time.Nanosecond)
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 50, account.AvailableLimit)
+		},
+		"should return error when account not initialized": func(t *testing.T) {
+			// 	given
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+			repository := NewMemoryRepository()
+
+			// 	when
+			account, _, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorize(givenTransaction))
+
+			// 	then
+			assert.Empty(t, account)
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+		},
+		"should not overdraw account when committing concurrently": func(t *testing.T) {
 			// 	given
 			repository := NewMemoryRepository()
-			initialAccount, err := repository.SaveAccount(domain.Account{ActiveCard: true, AvailableLimit: 100})
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
 			assert.NoError(t, err)
-			assert.NotEmpty(t, initialAccount)
 
 			// 	when
-			repository.UpdateAccountLimit(75)
+			wg := sync.WaitGroup{}
+			for i := 0; i < 500; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					transaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 1}, CreatedAt: time.Now().UTC()}
+					repository.CommitTransaction(transaction, time.Time{}, authorize(transaction))
+					repository.FindTransactionsAfter("1", time.Time{})
+					repository.FindAccount("1")
+				}()
+			}
+			wg.Wait()
 
 			// 	then
-			updatedAccount, err := repository.FindAccount()
+			account, err := repository.FindAccount("1")
 			assert.NoError(t, err)
-			assert.Equal(t, 75, updatedAccount.AvailableLimit)
+			assert.Equal(t, 0, account.AvailableLimit)
+			assert.Len(t, repository.FindTransactionsAfter("1", time.Time{}), 100)
 		},
 	}
 
@@ -218,3 +595,880 @@ func TestUpdateAccountLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestSimulateTransaction(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenTime}
+
+	testCases := map[string]func(*testing.T){
+		"should return account as it would be without recording transaction": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.SimulateTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 75, account.AvailableLimit)
+			storedAccount, _ := repository.FindAccount("1")
+			assert.Equal(t, 100, storedAccount.AvailableLimit)
+			assert.Empty(t, repository.FindTransactionsAfter("1", time.Time{}))
+			assert.Len(t, repository.FindEvents("1"), 1)
+		},
+		"should not record violations": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.SimulateTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction, domain.ErrInsufficientLimit))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrInsufficientLimit}, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 1)
+		},
+		"should return account with card change of rejected transaction without recording it": func(t *testing.T) {
+			// 	given
+			givenChange := domain.CardChange{AccountID: "1", Action: domain.CardBlock, Reason: "too-many-declines", CreatedAt: givenTime}
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.SimulateTransaction(givenTransaction, time.Time{}, lockAs(givenTransaction, givenChange, domain.ErrTooManyDeclines))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrTooManyDeclines}, errs)
+			assert.Equal(t, domain.CardBlocked, account.CardStatus())
+			assert.Len(t, repository.FindEvents("1"), 1)
+		},
+		"should authorize as if holds expired by transaction time were released": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Merchant: "hotel", Money: domain.Money{Amount: 60}, CreatedAt: givenTime.Add(-2 * time.Hour), ExpiresAt: givenTime.Add(-time.Hour)}
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+
+			// 	when
+			var spent int
+			account, _, err := repository.SimulateTransaction(givenTransaction, time.Time{}, func(_ domain.Account, _ []domain.Transaction, _ []domain.Transaction, spending domain.Spending) domain.Authorization {
+				spent = spending.SpentBetween(givenTime.Add(-24*time.Hour), givenTime)
+				return domain.Authorization{Transaction: givenTransaction}
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, 0, spent)
+			assert.Equal(t, 75, account.AvailableLimit)
+			hold, _ := repository.FindHold("1", "h1")
+			assert.Equal(t, domain.HoldHeld, hold.Status)
+		},
+		"should return error when account not initialized": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+
+			// 	when
+			_, _, err := repository.SimulateTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
+
+func TestCommitReversal(t *testing.T) {
+	givenTransaction := domain.Transaction{ID: "t1", AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+	givenReversal := domain.Reversal{AccountID: "1", TransactionID: "t1", CreatedAt: time.Now().UTC()}
+	validate := func(_ domain.Account, transaction domain.Transaction, found bool) []error {
+		if !found {
+			return []error{domain.ErrTransactionNotFound}
+		}
+		if transaction.Reversed {
+			return []error{domain.ErrTransactionAlreadyReversed}
+		}
+		return nil
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should record reversal and credit account": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			saveTransactions(&repository, givenTransaction)
+
+			// 	when
+			account, errs, err := repository.CommitReversal(givenReversal, validate)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.True(t, repository.transactions["1"].transactions[0].Reversed)
+			events := repository.FindEvents("1")
+			assert.Equal(t, domain.EventTransactionReversed, events[len(events)-1].Type)
+			assert.Equal(t, &givenReversal, events[len(events)-1].Reversal)
+		},
+		"should not record reversal when validate returns violations": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			saveTransactions(&repository, givenTransaction)
+			_, _, err = repository.CommitReversal(givenReversal, validate)
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitReversal(givenReversal, validate)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrTransactionAlreadyReversed}, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 3)
+		},
+		"should not expire holds when validate returns violations": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 60}, CreatedAt: givenTransaction.CreatedAt, ExpiresAt: givenTransaction.CreatedAt.Add(time.Hour)}
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			lateReversal := domain.Reversal{AccountID: "1", TransactionID: "t2", CreatedAt: givenHold.ExpiresAt.Add(time.Hour)}
+
+			// 	when
+			account, errs, err := repository.CommitReversal(lateReversal, validate)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrTransactionNotFound}, errs)
+			assert.Equal(t, 40, account.AvailableLimit)
+			hold, _ := repository.FindHold("1", "h1")
+			assert.Equal(t, domain.HoldHeld, hold.Status)
+		},
+		"should not find transaction of another account": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, err = repository.SaveAccount(domain.Account{ID: "2", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			saveTransactions(&repository, givenTransaction)
+
+			// 	when
+			account, errs, err := repository.CommitReversal(domain.Reversal{AccountID: "2", TransactionID: "t1"}, validate)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrTransactionNotFound}, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+		},
+		"should reverse transaction past the retention": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepositoryWithRetention(time.Hour, 0, 0, systemClock{})
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			pastTransaction := givenTransaction
+			pastTransaction.CreatedAt = givenTransaction.CreatedAt.Add(-2 * time.Hour)
+			saveTransactions(&repository, pastTransaction, domain.Transaction{ID: "t2", AccountID: "1", Money: domain.Money{Amount: 5}, CreatedAt: givenTransaction.CreatedAt})
+			_, _, err = repository.CommitReversal(givenReversal, validate)
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitReversal(givenReversal, validate)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrTransactionAlreadyReversed}, errs)
+			assert.Equal(t, 95, account.AvailableLimit)
+		},
+		"should return error when account not initialized": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+
+			// 	when
+			account, errs, err := repository.CommitReversal(givenReversal, validate)
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+			assert.Empty(t, errs)
+			assert.Empty(t, account)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
+
+func TestCommitHold(t *testing.T) {
+	givenHold := domain.Hold{
+		ID:        "h1",
+		AccountID: "1",
+		Money:     domain.Money{Amount: 60},
+		Merchant:  "hotel",
+		CreatedAt: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC),
+		ExpiresAt: time.Date(2019, 02, 14, 10, 0, 0, 0, time.UTC),
+	}
+	validate := func(_ domain.Account, hold domain.Hold, found bool) []error {
+		if !found || hold.Status != domain.HoldHeld {
+			return []error{domain.ErrHoldNotFound}
+		}
+		return nil
+	}
+	validateCapture := func(capture domain.Capture) func(domain.Account, domain.Hold, bool) (domain.Capture, []error) {
+		return func(account domain.Account, hold domain.Hold, found bool) (domain.Capture, []error) {
+			return capture, validate(account, hold, found)
+		}
+	}
+
+	testCases := map[string]func(*testing.T, *MemoryRepository){
+		"should reserve hold amount": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 40, account.AvailableLimit)
+			hold, found := repository.FindHold("1", "h1")
+			assert.True(t, found)
+			assert.Equal(t, domain.HoldHeld, hold.Status)
+			assert.Empty(t, repository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should not record hold when authorize returns violations": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold, domain.ErrInsufficientLimit))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrInsufficientLimit}, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 1)
+		},
+		"should return error when account already has hold with id": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrHoldExists)
+			assert.Empty(t, errs)
+			assert.Equal(t, 40, account.AvailableLimit)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should debit captured amount as transaction": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 45}, CreatedAt: givenHold.CreatedAt.Add(time.Hour)}
+
+			// 	when
+			account, errs, err := repository.CommitCapture(givenCapture, validateCapture(givenCapture))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 55, account.AvailableLimit)
+			hold, _ := repository.FindHold("1", "h1")
+			assert.Equal(t, domain.HoldCaptured, hold.Status)
+			assert.Equal(t, []domain.Transaction{{ID: "h1", AccountID: "1", Money: domain.Money{Amount: 45}, Merchant: "hotel", CreatedAt: givenCapture.CreatedAt}}, repository.FindTransactionsAfter("1", time.Time{}))
+		},
+		"should free hold amount when released": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+
+			// 	when
+			account, errs, err := repository.CommitRelease(domain.Release{AccountID: "1", HoldID: "h1", CreatedAt: givenHold.CreatedAt}, validate)
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 100, account.AvailableLimit)
+			hold, _ := repository.FindHold("1", "h1")
+			assert.Equal(t, domain.HoldReleased, hold.Status)
+		},
+		"should expire hold when operation time reaches its expiration": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 80}, CreatedAt: givenHold.ExpiresAt}
+
+			// 	when
+			account, errs, err := repository.CommitTransaction(givenTransaction, time.Time{}, func(account domain.Account, _ []domain.Transaction, _ []domain.Transaction, _ domain.Spending) domain.Authorization {
+				if account.AvailableLimit < givenTransaction.Amount {
+					return domain.Authorization{Transaction: givenTransaction, Violations: []error{domain.ErrInsufficientLimit}}
+				}
+				return domain.Authorization{Transaction: givenTransaction}
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 20, account.AvailableLimit)
+			hold, _ := repository.FindHold("1", "h1")
+			assert.Equal(t, domain.HoldExpired, hold.Status)
+			events := repository.FindEvents("1")
+			assert.Equal(t, domain.EventHoldExpired, events[2].Type)
+		},
+		"should not expire hold before its expiration": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 60}, CreatedAt: givenHold.ExpiresAt.Add(-time.Second)}
+
+			// 	when
+			account, errs, err := repository.CommitCapture(givenCapture, validateCapture(givenCapture))
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, 40, account.AvailableLimit)
+		},
+		"should forget hold settled before the history retention": func(t *testing.T, _ *MemoryRepository) {
+			// 	given
+			givenClock := &fakeClock{now: givenHold.CreatedAt}
+			givenRelease := domain.Release{AccountID: "1", HoldID: "h1", CreatedAt: givenHold.CreatedAt}
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 100}
+
+			repository := NewMemoryRepositoryWithRetention(0, 0, time.Hour, givenClock)
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitRelease(givenRelease, validate)
+			assert.NoError(t, err)
+			givenClock.advance(2 * time.Hour)
+
+			// 	when
+			_, _, err = repository.CommitLimitChange(givenChange, func(domain.Account) []error {
+				return nil
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			_, found := repository.FindHold("1", "h1")
+			assert.False(t, found)
+		},
+		"should return error when account not initialized": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitHold(domain.Hold{ID: "h1", AccountID: "2"}, time.Time{}, placeAs(domain.Hold{ID: "h1", AccountID: "2"}))
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+			assert.Empty(t, errs)
+			assert.Empty(t, account)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			run(t, &repository)
+		})
+	}
+}
+
+func TestCommitCardChange(t *testing.T) {
+	givenChange := domain.CardChange{AccountID: "1", Action: domain.CardBlock, Reason: "lost", CreatedAt: time.Now().UTC()}
+
+	testCases := map[string]func(*testing.T, *MemoryRepository){
+		"should record card change": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitCardChange(givenChange, func(domain.Account) []error {
+				return nil
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, domain.Account{ID: "1", AvailableLimit: 100, BlockReason: "lost"}, account)
+			events := repository.FindEvents("1")
+			assert.Equal(t, domain.EventCardChanged, events[1].Type)
+			assert.Equal(t, &givenChange, events[1].CardChange)
+		},
+		"should not record card change when validate returns violations": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitCardChange(givenChange, func(domain.Account) []error {
+				return []error{domain.ErrCardTransitionNotAllowed}
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrCardTransitionNotAllowed}, errs)
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100}, account)
+			assert.Len(t, repository.FindEvents("1"), 1)
+		},
+		"should return error when account not initialized": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitCardChange(domain.CardChange{AccountID: "2", Action: domain.CardClose}, func(domain.Account) []error {
+				return nil
+			})
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+			assert.Empty(t, errs)
+			assert.Empty(t, account)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			run(t, &repository)
+		})
+	}
+}
+
+func TestCommitLimitChange(t *testing.T) {
+	givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 300, CreatedAt: time.Now().UTC()}
+
+	testCases := map[string]func(*testing.T, *MemoryRepository){
+		"should record limit change moving available limit": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitLimitChange(givenChange, func(domain.Account) []error {
+				return nil
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Empty(t, errs)
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 240, TotalLimit: 300}, account)
+			events := repository.FindEvents("1")
+			assert.Equal(t, domain.EventTotalLimitChanged, events[2].Type)
+			assert.Equal(t, &givenChange, events[2].LimitChange)
+		},
+		"should not record limit change when validate returns violations": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitLimitChange(givenChange, func(domain.Account) []error {
+				return []error{domain.ErrLimitBelowUsed}
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []error{domain.ErrLimitBelowUsed}, errs)
+			assert.Equal(t, domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 40, TotalLimit: 100}, account)
+			assert.Len(t, repository.FindEvents("1"), 2)
+		},
+		"should return error when account not initialized": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			account, errs, err := repository.CommitLimitChange(domain.LimitChange{AccountID: "2", TotalLimit: 300}, func(domain.Account) []error {
+				return nil
+			})
+
+			// 	then
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+			assert.Empty(t, errs)
+			assert.Empty(t, account)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100})
+			assert.NoError(t, err)
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 60}, CreatedAt: time.Now().UTC()}
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+
+			run(t, &repository)
+		})
+	}
+}
+
+func TestSpentBetween(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+
+	testCases := map[string]func(*testing.T, *MemoryRepository){
+		"should sum authorized transactions within range": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			for i, amount := range []int{10, 20, 30} {
+				transaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: amount}, CreatedAt: givenTime.Add(time.Duration(i) * time.Hour)}
+				_, _, err := repository.CommitTransaction(transaction, time.Time{}, authorizeAs(transaction))
+				assert.NoError(t, err)
+			}
+
+			// 	when
+			spent := repository.SpentBetween("1", givenTime, givenTime.Add(2*time.Hour))
+
+			// 	then
+			assert.Equal(t, 30, spent)
+		},
+		"should leave out reversed transactions": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			givenTransaction := domain.Transaction{ID: "t1", AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 10}, CreatedAt: givenTime}
+			_, _, err := repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitReversal(
+				domain.Reversal{AccountID: "1", TransactionID: "t1", CreatedAt: givenTime.Add(time.Hour)},
+				func(domain.Account, domain.Transaction, bool) []error { return nil },
+			)
+			assert.NoError(t, err)
+
+			// 	when
+			spent := repository.SpentBetween("1", givenTime, givenTime.Add(24*time.Hour))
+
+			// 	then
+			assert.Equal(t, 0, spent)
+		},
+		"should count holds while held and captures once captured": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Merchant: "hotel", Money: domain.Money{Amount: 60}, CreatedAt: givenTime, ExpiresAt: givenTime.Add(24 * time.Hour)}
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			heldSpent := repository.SpentBetween("1", givenTime, givenTime.Add(time.Hour))
+
+			// 	when
+			givenCapture := domain.Capture{AccountID: "1", HoldID: "h1", Money: domain.Money{Amount: 45}, CreatedAt: givenTime.Add(2 * time.Hour)}
+			_, _, err = repository.CommitCapture(givenCapture, func(domain.Account, domain.Hold, bool) (domain.Capture, []error) {
+				return givenCapture, nil
+			})
+			assert.NoError(t, err)
+
+			// 	then
+			assert.Equal(t, 60, heldSpent)
+			assert.Equal(t, 0, repository.SpentBetween("1", givenTime, givenTime.Add(time.Hour)))
+			assert.Equal(t, 45, repository.SpentBetween("1", givenTime, givenTime.Add(3*time.Hour)))
+		},
+		"should sum spending at merchant and category with holds while held": func(t *testing.T, repository *MemoryRepository) {
+			// 	given
+			givenHold := domain.Hold{ID: "h1", AccountID: "1", Merchant: "casino", MCC: "7995", Money: domain.Money{Amount: 60}, CreatedAt: givenTime, ExpiresAt: givenTime.Add(24 * time.Hour)}
+			_, _, err := repository.CommitHold(givenHold, time.Time{}, placeAs(givenHold))
+			assert.NoError(t, err)
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", MCC: "5812", Money: domain.Money{Amount: 10}, CreatedAt: givenTime}
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+
+			// 	when
+			spending := repository.spendingOf("1")
+
+			// 	then
+			assert.Equal(t, 70, spending.SpentBetween(givenTime, givenTime.Add(time.Hour)))
+			assert.Equal(t, 60, spending.AtMerchant("casino").SpentBetween(givenTime, givenTime.Add(time.Hour)))
+			assert.Equal(t, 60, spending.InCategory("7995").SpentBetween(givenTime, givenTime.Add(time.Hour)))
+			assert.Equal(t, 10, spending.InCategory("5812").SpentBetween(givenTime, givenTime.Add(time.Hour)))
+			assert.Equal(t, 0, spending.AtMerchant("hotel").SpentBetween(givenTime, givenTime.Add(time.Hour)))
+		},
+		"should return zero when account has no spending": func(t *testing.T, repository *MemoryRepository) {
+			// 	when
+			spent := repository.SpentBetween("2", givenTime, givenTime.Add(time.Hour))
+
+			// 	then
+			assert.Equal(t, 0, spent)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+
+			run(t, &repository)
+		})
+	}
+}
+
+func TestFindEvents(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"should return account ledger in sequence order": func(t *testing.T) {
+			// 	given
+			givenAccount := domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100}
+			givenTransaction := domain.Transaction{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()}
+			givenChange := domain.LimitChange{AccountID: "1", TotalLimit: 125}
+
+			repository := NewMemoryRepository()
+			_, err := repository.SaveAccount(givenAccount)
+			assert.NoError(t, err)
+			_, err = repository.SaveAccount(domain.Account{ID: "2", ActiveCard: true, AvailableLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransaction, time.Time{}, authorizeAs(givenTransaction))
+			assert.NoError(t, err)
+			_, _, err = repository.CommitLimitChange(givenChange, func(domain.Account) []error {
+				return nil
+			})
+			assert.NoError(t, err)
+
+			// 	when
+			events := repository.FindEvents("1")
+
+			// 	then
+			assert.Len(t, events, 3)
+			assert.Equal(t, []uint64{1, 3, 4}, []uint64{events[0].Sequence, events[1].Sequence, events[2].Sequence})
+			assert.Equal(t, []domain.EventType{domain.EventAccountCreated, domain.EventTransactionAuthorized, domain.EventTotalLimitChanged}, []domain.EventType{events[0].Type, events[1].Type, events[2].Type})
+			assert.Equal(t, &givenAccount, events[0].Account)
+			assert.Equal(t, &givenTransaction, events[1].Transaction)
+			assert.Equal(t, &givenChange, events[2].LimitChange)
+		},
+		"should trim events recorded before the history retention into the account they fold into": func(t *testing.T) {
+			// 	given
+			givenTransactions := []domain.Transaction{
+				{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: time.Now().UTC()},
+				{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 10}, CreatedAt: time.Now().UTC()},
+			}
+			givenClock := &fakeClock{now: time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)}
+
+			repository := NewMemoryRepositoryWithRetention(0, 0, time.Hour, givenClock)
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransactions[0], time.Time{}, authorizeAs(givenTransactions[0]))
+			assert.NoError(t, err)
+			givenClock.advance(time.Hour + time.Nanosecond)
+
+			// 	when
+			_, _, err = repository.CommitTransaction(givenTransactions[1], time.Time{}, authorizeAs(givenTransactions[1]))
+
+			// 	then
+			events := repository.FindEvents("1")
+			assert.NoError(t, err)
+			assert.Len(t, events, 2)
+			assert.Equal(t, domain.NewHistoryTrimmed(
+				domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100},
+				2,
+				givenClock.now.Add(-time.Hour-time.Nanosecond),
+			), events[0])
+			assert.Equal(t, &givenTransactions[1], events[1].Transaction)
+			assert.Equal(t, 65, domain.Fold(events).AvailableLimit)
+		},
+		"should return empty ledger when account not initialized": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+
+			// 	when
+			events := repository.FindEvents("1")
+
+			// 	then
+			assert.Empty(t, events)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func TestFindDecisions(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenTransactions := []domain.Transaction{
+		{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 25}, CreatedAt: givenTime},
+		{AccountID: "1", Merchant: "ifood", Money: domain.Money{Amount: 250}, CreatedAt: givenTime.Add(time.Minute)},
+		{AccountID: "1", Merchant: "uber-eats", Money: domain.Money{Amount: 25}, CreatedAt: givenTime.Add(2 * time.Minute)},
+	}
+	givenRepository := func(t *testing.T) MemoryRepository {
+		repository := NewMemoryRepository()
+		_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100})
+		assert.NoError(t, err)
+
+		repository.mutex.Lock()
+		defer repository.mutex.Unlock()
+		repository.record(domain.NewTransactionAuthorized(givenTransactions[0], givenTime))
+		repository.record(domain.NewTransactionRejected(givenTransactions[1], []error{domain.ErrInsufficientLimit}, givenTime.Add(time.Minute)))
+		repository.record(domain.NewTransactionRejected(givenTransactions[2], []error{domain.ErrDoubleTransaction}, givenTime.Add(2*time.Minute)))
+		return repository
+	}
+
+	testCases := map[string]func(*testing.T){
+		"should return every decision with account right after it": func(t *testing.T) {
+			// 	given
+			repository := givenRepository(t)
+
+			// 	when
+			decisions, err := repository.FindDecisions("1", domain.DecisionFilter{})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Equal(t, []domain.Decision{
+				{
+					Outcome:     domain.OutcomeAuthorized,
+					Transaction: givenTransactions[0],
+					Account:     domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100},
+					Violations:  []string{},
+					DecidedAt:   givenTime,
+				},
+				{
+					Outcome:     domain.OutcomeRejected,
+					Transaction: givenTransactions[1],
+					Account:     domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100},
+					Violations:  []string{"insufficient-limit"},
+					DecidedAt:   givenTime.Add(time.Minute),
+				},
+				{
+					Outcome:     domain.OutcomeRejected,
+					Transaction: givenTransactions[2],
+					Account:     domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 75, TotalLimit: 100},
+					Violations:  []string{"double-transaction"},
+					DecidedAt:   givenTime.Add(2 * time.Minute),
+				},
+			}, decisions)
+		},
+		"should filter decisions by outcome": func(t *testing.T) {
+			// 	given
+			repository := givenRepository(t)
+
+			// 	when
+			decisions, err := repository.FindDecisions("1", domain.DecisionFilter{Outcome: domain.OutcomeRejected})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Len(t, decisions, 2)
+		},
+		"should filter decisions by violation": func(t *testing.T) {
+			// 	given
+			repository := givenRepository(t)
+
+			// 	when
+			decisions, err := repository.FindDecisions("1", domain.DecisionFilter{Violation: "double-transaction"})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Len(t, decisions, 1)
+			assert.Equal(t, givenTransactions[2], decisions[0].Transaction)
+		},
+		"should filter decisions by time range": func(t *testing.T) {
+			// 	given
+			repository := givenRepository(t)
+
+			// 	when
+			decisions, err := repository.FindDecisions("1", domain.DecisionFilter{
+				From: givenTime.Add(time.Minute),
+				To:   givenTime.Add(2 * time.Minute),
+			})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Len(t, decisions, 1)
+			assert.Equal(t, givenTransactions[1], decisions[0].Transaction)
+		},
+		"should forget decisions made before the history retention": func(t *testing.T) {
+			// 	given
+			givenClock := &fakeClock{now: givenTime}
+
+			repository := NewMemoryRepositoryWithRetention(0, 0, time.Hour, givenClock)
+			_, err := repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: 100, TotalLimit: 100})
+			assert.NoError(t, err)
+			_, _, err = repository.CommitTransaction(givenTransactions[0], time.Time{}, authorizeAs(givenTransactions[0]))
+			assert.NoError(t, err)
+			givenClock.advance(2 * time.Hour)
+			_, _, err = repository.CommitTransaction(givenTransactions[2], time.Time{}, authorizeAs(givenTransactions[2]))
+			assert.NoError(t, err)
+
+			// 	when
+			decisions, err := repository.FindDecisions("1", domain.DecisionFilter{})
+
+			// 	then
+			assert.NoError(t, err)
+			assert.Len(t, decisions, 1)
+			assert.Equal(t, givenTransactions[2], decisions[0].Transaction)
+		},
+		"should return error when account not initialized": func(t *testing.T) {
+			// 	given
+			repository := NewMemoryRepository()
+
+			// 	when
+			decisions, err := repository.FindDecisions("1", domain.DecisionFilter{})
+
+			// 	then
+			assert.Empty(t, decisions)
+			assert.ErrorIs(t, err, domain.ErrAccountNotFound)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
+
+func BenchmarkCommitTransaction(b *testing.B) {
+	for _, count := range []int{1000, 100000, 1000000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+			repository := NewMemoryRepositoryWithRetention(2*time.Minute, 0, 0, systemClock{})
+			_, _ = repository.SaveAccount(domain.Account{ID: "1", ActiveCard: true, AvailableLimit: math.MaxInt32})
+			for i := 0; i < count; i++ {
+				saveTransactions(&repository, domain.Transaction{ID: strconv.Itoa(i), AccountID: "1", Money: domain.Money{Amount: 1}, CreatedAt: givenTime})
+				givenTime = givenTime.Add(time.Second)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				transaction := domain.Transaction{AccountID: "1", Money: domain.Money{Amount: 1}, CreatedAt: givenTime}
+				_, _, _ = repository.CommitTransaction(transaction, givenTime.Add(-2*time.Minute), authorizeAs(transaction))
+				givenTime = givenTime.Add(time.Second)
+			}
+		})
+	}
+}
+
+// saveTransactions records the given transactions as authorized without checking their accounts.
+func saveTransactions(repository *MemoryRepository, transactions ...domain.Transaction) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	for _, transaction := range transactions {
+		repository.record(domain.NewTransactionAuthorized(transaction, time.Now().UTC()))
+	}
+}
+
+// fakeClock tells the time it is set to, so time-based checks are deterministic, until it is advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// authorizeAs returns an authorize callback answering the given transaction with the given violations.
+func authorizeAs(transaction domain.Transaction, errs ...error) func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization {
+	return func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization {
+		return domain.Authorization{Transaction: transaction, Violations: errs}
+	}
+}
+
+// placeAs returns an authorize callback answering the given hold with the given violations.
+func placeAs(hold domain.Hold, errs ...error) func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) (domain.Hold, []error) {
+	return func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) (domain.Hold, []error) {
+		return hold, errs
+	}
+}
+
+// lockAs returns an authorize callback rejecting the given transaction with the given violations and card change.
+func lockAs(transaction domain.Transaction, change domain.CardChange, errs ...error) func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization {
+	return func(domain.Account, []domain.Transaction, []domain.Transaction, domain.Spending) domain.Authorization {
+		return domain.Authorization{Transaction: transaction, Violations: errs, CardChange: &change}
+	}
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+type (
+	// memoryState is what a memory repository holds, written as it is to a snapshot and restored without replaying
+	// the events it was projected from, so the events no longer needed can be dropped from the log.
+	memoryState struct {
+		Sequence         uint64                           `json:"seq"`
+		Accounts         map[string]domain.Account        `json:"accounts"`
+		Events           map[string][]domain.Event        `json:"events"`
+		Transactions     map[string]transactionState      `json:"transactions"`
+		Declines         map[string]transactionState      `json:"declines"`
+		Holds            map[string][]domain.Hold         `json:"holds"`
+		Spending         map[string]spendState            `json:"spending"`
+		MerchantSpending map[string]map[string]spendState `json:"merchant-spending"`
+		CategorySpending map[string]map[string]spendState `json:"category-spending"`
+		Keys             []keyState                       `json:"keys"`
+		Decisions        map[string][]domain.Decision     `json:"decisions"`
+	}
+
+	transactionState struct {
+		Transactions  []domain.Transaction `json:"transactions"`
+		Latest        time.Time            `json:"latest"`
+		EvictedBefore time.Time            `json:"evicted-before"`
+	}
+
+	spendState struct {
+		Times         []time.Time `json:"times"`
+		Totals        []int       `json:"totals"`
+		Latest        time.Time   `json:"latest"`
+		EvictedTotal  int         `json:"evicted-total"`
+		EvictedBefore time.Time   `json:"evicted-before"`
+	}
+
+	keyState struct {
+		AccountID   string              `json:"account-id"`
+		Key         string              `json:"key"`
+		Account     domain.Account      `json:"account"`
+		Violations  []domain.Violation  `json:"violations,omitempty"`
+		Transaction *domain.Transaction `json:"transaction,omitempty"`
+		RecordedAt  time.Time           `json:"recorded-at"`
+	}
+)
+
+// state returns what the repository holds, sharing its maps, so the repository must not change while the state is in
+// use.
+func (m *MemoryRepository) state() memoryState {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	state := memoryState{
+		Sequence:     m.sequence,
+		Accounts:     m.accounts,
+		Events:       m.events,
+		Transactions: map[string]transactionState{},
+		Declines:     map[string]transactionState{},
+		Holds:        m.holds,
+		Spending:     map[string]spendState{},
+		Decisions:    m.decisions,
+	}
+	for accountID, index := range m.transactions {
+		state.Transactions[accountID] = transactionState{
+			Transactions:  index.transactions,
+			Latest:        index.latest,
+			EvictedBefore: index.evictedBefore,
+		}
+	}
+	for accountID, index := range m.declines {
+		state.Declines[accountID] = transactionState{
+			Transactions:  index.transactions,
+			Latest:        index.latest,
+			EvictedBefore: index.evictedBefore,
+		}
+	}
+	for accountID, index := range m.spending {
+		state.Spending[accountID] = spendStateOf(index)
+	}
+	state.MerchantSpending = spendStatesOf(m.merchantSpending)
+	state.CategorySpending = spendStatesOf(m.categorySpending)
+	for _, id := range m.keys.order {
+		if result, ok := m.keys.results[id]; ok {
+			state.Keys = append(state.Keys, keyState{
+				AccountID:   id.accountID,
+				Key:         id.key,
+				Account:     result.account,
+				Violations:  result.violations,
+				Transaction: result.transaction,
+				RecordedAt:  result.recordedAt,
+			})
+		}
+	}
+	return state
+}
+
+// restore replaces what the repository holds with the given state.
+func (m *MemoryRepository) restore(state memoryState) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.sequence = state.Sequence
+	m.accounts = map[string]domain.Account{}
+	for accountID, account := range state.Accounts {
+		m.accounts[accountID] = account
+	}
+	m.events = map[string][]domain.Event{}
+	for accountID, events := range state.Events {
+		m.events[accountID] = events
+	}
+	m.holds = map[string][]domain.Hold{}
+	for accountID, holds := range state.Holds {
+		m.holds[accountID] = holds
+	}
+	m.decisions = map[string][]domain.Decision{}
+	for accountID, decisions := range state.Decisions {
+		m.decisions[accountID] = decisions
+	}
+	m.transactions = m.restoreIndexes(state.Transactions)
+	m.declines = m.restoreIndexes(state.Declines)
+	m.spending = map[string]*spendIndex{}
+	for accountID, spending := range state.Spending {
+		m.spending[accountID] = spending.index()
+	}
+	m.merchantSpending = spendIndexesOf(state.MerchantSpending)
+	m.categorySpending = spendIndexesOf(state.CategorySpending)
+	m.keys = newIdempotencyKeys(m.keys.retention)
+	for _, key := range state.Keys {
+		id := idempotencyKey{accountID: key.AccountID, key: key.Key}
+		m.keys.results[id] = keyResult{
+			account:     key.Account,
+			violations:  key.Violations,
+			transaction: key.Transaction,
+			recordedAt:  key.RecordedAt,
+		}
+		m.keys.order = append(m.keys.order, id)
+	}
+}
+
+// restoreIndexes returns the transaction indexes of the given states, with the retention of the repository.
+func (m *MemoryRepository) restoreIndexes(states map[string]transactionState) map[string]*transactionIndex {
+	indexes := map[string]*transactionIndex{}
+	for accountID, state := range states {
+		index := newTransactionIndex(m.retention)
+		index.transactions = state.Transactions
+		index.latest = state.Latest
+		index.evictedBefore = state.EvictedBefore
+		for _, transaction := range state.Transactions {
+			if transaction.ID != "" {
+				index.times[transaction.ID] = transaction.CreatedAt
+			}
+		}
+		indexes[accountID] = index
+	}
+	return indexes
+}
+
+// spendStateOf returns the state of the spend index.
+func spendStateOf(index *spendIndex) spendState {
+	return spendState{
+		Times:         index.times,
+		Totals:        index.totals,
+		Latest:        index.latest,
+		EvictedTotal:  index.evictedTotal,
+		EvictedBefore: index.evictedBefore,
+	}
+}
+
+// spendStatesOf returns the states of the spend indexes of each account by their keys.
+func spendStatesOf(indexes map[string]map[string]*spendIndex) map[string]map[string]spendState {
+	states := map[string]map[string]spendState{}
+	for accountID, keyIndexes := range indexes {
+		states[accountID] = map[string]spendState{}
+		for key, index := range keyIndexes {
+			states[accountID][key] = spendStateOf(index)
+		}
+	}
+	return states
+}
+
+// spendIndexesOf returns the spend indexes of the given states of each account by their keys.
+func spendIndexesOf(states map[string]map[string]spendState) map[string]map[string]*spendIndex {
+	indexes := map[string]map[string]*spendIndex{}
+	for accountID, keyStates := range states {
+		indexes[accountID] = map[string]*spendIndex{}
+		for key, state := range keyStates {
+			indexes[accountID][key] = state.index()
+		}
+	}
+	return indexes
+}
+
+// index returns the spend index of the state.
+func (s spendState) index() *spendIndex {
+	return &spendIndex{
+		times:         s.Times,
+		totals:        s.Totals,
+		latest:        s.Latest,
+		evictedTotal:  s.EvictedTotal,
+		evictedBefore: s.EvictedBefore,
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+// spendRetention is how long the amounts spent before the latest one are kept, the longest limit period, a calendar
+// month, with a day to spare for the timezone it starts in.
+const spendRetention = 32 * 24 * time.Hour
+
+// spendIndex keeps the amounts spent by an account in time order along with their running totals, so the amount spent
+// over any time range is found with two binary searches. Amounts given back, like reversed ones, are kept as negative
+// amounts at the time they were spent. Amounts older than the spend retention before the latest one are evicted, their
+// total kept in evictedTotal, so the ranges after evictedBefore still sum right. The latest amount is the latest one
+// not spent after the time it was recorded, so an amount dated in the future can't evict the ones still in use.
+type spendIndex struct {
+	times         []time.Time
+	totals        []int
+	latest        time.Time
+	evictedTotal  int
+	evictedBefore time.Time
+}
+
+// add inserts the amount spent at the given time, recorded at the other, after any amount spent at the same time, then
+// evicts the ones past the retention. Amounts mostly arrive in time order, so the running totals after it rarely need
+// an update. An amount already past the retention is dropped.
+func (s *spendIndex) add(at time.Time, amount int, recordedAt time.Time) {
+	if at.Before(s.evictedBefore) {
+		return
+	}
+	if at.After(s.latest) && !at.After(recordedAt) {
+		s.latest = at
+	}
+
+	i := sort.Search(len(s.times), func(i int) bool {
+		return s.times[i].After(at)
+	})
+
+	s.times = append(s.times, time.Time{})
+	copy(s.times[i+1:], s.times[i:])
+	s.times[i] = at
+
+	s.totals = append(s.totals, 0)
+	copy(s.totals[i+1:], s.totals[i:])
+	s.totals[i] = s.totalBefore(i) + amount
+	for j := i + 1; j < len(s.totals); j++ {
+		s.totals[j] += amount
+	}
+
+	s.evict()
+}
+
+// evict drops the amounts older than the retention before the latest one. The dropped prefix is released once
+// appending outgrows the backing array.
+func (s *spendIndex) evict() {
+	if s.latest.IsZero() {
+		return
+	}
+
+	horizon := s.latest.Add(-spendRetention)
+	if !horizon.After(s.evictedBefore) {
+		return
+	}
+
+	i := sort.Search(len(s.times), func(i int) bool {
+		return !s.times[i].Before(horizon)
+	})
+	s.evictedTotal = s.totalBefore(i)
+	s.times = s.times[i:]
+	s.totals = s.totals[i:]
+	s.evictedBefore = horizon
+}
+
+// sum returns the amount spent from the given time, inclusive, to the other, exclusive.
+func (s *spendIndex) sum(from time.Time, to time.Time) int {
+	if s == nil {
+		return 0
+	}
+
+	start := sort.Search(len(s.times), func(i int) bool {
+		return !s.times[i].Before(from)
+	})
+	end := sort.Search(len(s.times), func(i int) bool {
+		return !s.times[i].Before(to)
+	})
+	if end <= start {
+		return 0
+	}
+	return s.totalBefore(end) - s.totalBefore(start)
+}
+
+// totalBefore returns the running total of the amounts before the i-th one, evicted ones included.
+func (s *spendIndex) totalBefore(i int) int {
+	if i == 0 {
+		return s.evictedTotal
+	}
+	return s.totals[i-1]
+}
+
+// accountSpending is the spending of an account as seen by the authorization of its operations, along with its
+// spending at each merchant and category.
+type accountSpending struct {
+	index      *spendIndex
+	merchants  map[string]*spendIndex
+	categories map[string]*spendIndex
+}
+
+func (s accountSpending) SpentBetween(from time.Time, to time.Time) int {
+	return s.index.sum(from, to)
+}
+
+func (s accountSpending) AtMerchant(merchant string) domain.Spending {
+	return accountSpending{index: s.merchants[merchant]}
+}
+
+func (s accountSpending) InCategory(mcc string) domain.Spending {
+	return accountSpending{index: s.categories[mcc]}
+}
+
+// releasedSpending is a spending without the amounts of the given holds, as if they were released.
+type releasedSpending struct {
+	domain.Spending
+	holds []domain.Hold
+}
+
+func (s releasedSpending) SpentBetween(from time.Time, to time.Time) int {
+	spent := s.Spending.SpentBetween(from, to)
+	for _, hold := range s.holds {
+		if !hold.CreatedAt.Before(from) && hold.CreatedAt.Before(to) {
+			spent -= hold.Amount
+		}
+	}
+	return spent
+}
+
+func (s releasedSpending) AtMerchant(merchant string) domain.Spending {
+	holds := []domain.Hold{}
+	for _, hold := range s.holds {
+		if hold.Merchant == merchant {
+			holds = append(holds, hold)
+		}
+	}
+	return releasedSpending{Spending: s.Spending.AtMerchant(merchant), holds: holds}
+}
+
+func (s releasedSpending) InCategory(mcc string) domain.Spending {
+	holds := []domain.Hold{}
+	for _, hold := range s.holds {
+		if hold.MCC == mcc {
+			holds = append(holds, hold)
+		}
+	}
+	return releasedSpending{Spending: s.Spending.InCategory(mcc), holds: holds}
+}
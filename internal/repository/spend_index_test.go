@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpendIndex(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenNow := givenTime.Add(2 * spendRetention)
+
+	testCases := map[string]func(*testing.T){
+		"should sum amounts within range": func(t *testing.T) {
+			// 	given
+			index := &spendIndex{}
+			index.add(givenTime, 10, givenNow)
+			index.add(givenTime.Add(time.Hour), 20, givenNow)
+			index.add(givenTime.Add(2*time.Hour), 30, givenNow)
+
+			// 	when
+			spent := index.sum(givenTime.Add(time.Hour), givenTime.Add(2*time.Hour))
+
+			// 	then
+			assert.Equal(t, 20, spent)
+		},
+		"should keep amounts added out of order in time order": func(t *testing.T) {
+			// 	given
+			index := &spendIndex{}
+			index.add(givenTime.Add(2*time.Hour), 30, givenNow)
+			index.add(givenTime, 10, givenNow)
+			index.add(givenTime.Add(time.Hour), 20, givenNow)
+
+			// 	when
+			spent := index.sum(givenTime, givenTime.Add(90*time.Minute))
+
+			// 	then
+			assert.Equal(t, 30, spent)
+			assert.Equal(t, []int{10, 30, 60}, index.totals)
+		},
+		"should net negative amounts at the time they were spent": func(t *testing.T) {
+			// 	given
+			index := &spendIndex{}
+			index.add(givenTime, 10, givenNow)
+			index.add(givenTime.Add(time.Hour), 20, givenNow)
+			index.add(givenTime, -10, givenNow)
+
+			// 	when
+			spent := index.sum(givenTime, givenTime.Add(time.Minute))
+
+			// 	then
+			assert.Equal(t, 0, spent)
+			assert.Equal(t, 20, index.sum(givenTime, givenTime.Add(2*time.Hour)))
+		},
+		"should evict amounts older than the spend retention before the latest one": func(t *testing.T) {
+			// 	given
+			index := &spendIndex{}
+			index.add(givenTime, 10, givenNow)
+			index.add(givenTime.Add(24*time.Hour), 20, givenNow)
+
+			// 	when
+			index.add(givenTime.Add(spendRetention+time.Hour), 30, givenNow)
+
+			// 	then
+			assert.Len(t, index.times, 2)
+			assert.Equal(t, 50, index.sum(givenTime.Add(time.Hour), givenTime.Add(spendRetention+2*time.Hour)))
+		},
+		"should drop amounts already past the spend retention": func(t *testing.T) {
+			// 	given
+			index := &spendIndex{}
+			index.add(givenTime, 10, givenNow)
+			index.add(givenTime.Add(spendRetention+time.Hour), 20, givenNow)
+
+			// 	when
+			index.add(givenTime.Add(time.Minute), 30, givenNow)
+
+			// 	then
+			assert.Len(t, index.times, 1)
+			assert.Equal(t, 20, index.sum(givenTime.Add(time.Hour), givenTime.Add(spendRetention+2*time.Hour)))
+		},
+		"should not evict amounts before one dated in the future": func(t *testing.T) {
+			// 	given
+			index := &spendIndex{}
+			index.add(givenTime, 90, givenTime)
+
+			// 	when
+			index.add(givenTime.AddDate(80, 0, 0), 1, givenTime.Add(time.Minute))
+			index.add(givenTime.Add(time.Hour), 90, givenTime.Add(time.Hour))
+
+			// 	then
+			assert.Len(t, index.times, 3)
+			assert.Equal(t, 180, index.sum(givenTime, givenTime.Add(24*time.Hour)))
+		},
+		"should return zero when index is empty": func(t *testing.T) {
+			// 	given
+			var index *spendIndex
+
+			// 	when
+			spent := index.sum(givenTime, givenTime.Add(time.Hour))
+
+			// 	then
+			assert.Equal(t, 0, spent)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, func(t *testing.T) {
+			run(t)
+		})
+	}
+}
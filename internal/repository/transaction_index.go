@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+// transactionIndex keeps the authorized transactions of an account sorted by time, so the ones after a given time are
+// found with a binary search. With a retention, transactions older than the retention before the latest one are
+// evicted, and evictedBefore tells which lookups must go back to the ledger for them. The latest transaction is the
+// latest one not made after the time it was recorded, so a transaction dated in the future can't evict the others.
+type transactionIndex struct {
+	retention     time.Duration
+	transactions  []domain.Transaction
+	times         map[string]time.Time
+	latest        time.Time
+	evictedBefore time.Time
+}
+
+func newTransactionIndex(retention time.Duration) *transactionIndex {
+	return &transactionIndex{
+		retention: retention,
+		times:     map[string]time.Time{},
+	}
+}
+
+// add inserts the transaction recorded at the given time after any transaction made at the same time, then evicts the
+// ones past the retention. A transaction already past the retention is left to the ledger.
+func (x *transactionIndex) add(transaction domain.Transaction, recordedAt time.Time) {
+	if transaction.CreatedAt.Before(x.evictedBefore) {
+		return
+	}
+	if transaction.CreatedAt.After(x.latest) && !transaction.CreatedAt.After(recordedAt) {
+		x.latest = transaction.CreatedAt
+	}
+
+	i := sort.Search(len(x.transactions), func(i int) bool {
+		return x.transactions[i].CreatedAt.After(transaction.CreatedAt)
+	})
+	x.transactions = append(x.transactions, domain.Transaction{})
+	copy(x.transactions[i+1:], x.transactions[i:])
+	x.transactions[i] = transaction
+	if transaction.ID != "" {
+		x.times[transaction.ID] = transaction.CreatedAt
+	}
+
+	x.evict()
+}
+
+// evict drops the transactions older than the retention before the latest one. The dropped prefix is released once
+// appending outgrows the backing array.
+func (x *transactionIndex) evict() {
+	if x.retention <= 0 || x.latest.IsZero() {
+		return
+	}
+
+	horizon := x.latest.Add(-x.retention)
+	if !horizon.After(x.evictedBefore) {
+		return
+	}
+
+	i := sort.Search(len(x.transactions), func(i int) bool {
+		return !x.transactions[i].CreatedAt.Before(horizon)
+	})
+	for _, transaction := range x.transactions[:i] {
+		delete(x.times, transaction.ID)
+	}
+	x.transactions = x.transactions[i:]
+	x.evictedBefore = horizon
+}
+
+// after returns the transactions made after the given time, complete tells whether none of them was evicted.
+func (x *transactionIndex) after(at time.Time) (transactions []domain.Transaction, complete bool) {
+	i := sort.Search(len(x.transactions), func(i int) bool {
+		return x.transactions[i].CreatedAt.After(at)
+	})
+	return append([]domain.Transaction{}, x.transactions[i:]...), !at.Before(x.evictedBefore)
+}
+
+// find returns the position of the transaction with the given id, found tells whether it is still indexed.
+func (x *transactionIndex) find(transactionID string) (int, bool) {
+	createdAt, ok := x.times[transactionID]
+	if !ok {
+		return 0, false
+	}
+
+	i := sort.Search(len(x.transactions), func(i int) bool {
+		return !x.transactions[i].CreatedAt.Before(createdAt)
+	})
+	for ; i < len(x.transactions) && x.transactions[i].CreatedAt.Equal(createdAt); i++ {
+		if x.transactions[i].ID == transactionID {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// complete tells whether no transaction was evicted, so a transaction missing from the index is missing for good.
+func (x *transactionIndex) complete() bool {
+	return x.evictedBefore.IsZero()
+}
+
+// ledgerTransactions projects the authorized transactions of an account from its ledger, in time order.
+func ledgerTransactions(events []domain.Event) []domain.Transaction {
+	transactions := []domain.Transaction{}
+	for _, event := range events {
+		switch event.Type {
+		case domain.EventTransactionAuthorized, domain.EventHoldCaptured:
+			transactions = append(transactions, *event.Transaction)
+		case domain.EventTransactionReversed:
+			for i, transaction := range transactions {
+				if transaction.ID == event.Transaction.ID {
+					transactions[i].Reversed = true
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+	})
+	return transactions
+}
+
+// ledgerDeclines projects the rejected transactions of an account from its ledger, in time order.
+func ledgerDeclines(events []domain.Event) []domain.Transaction {
+	declines := []domain.Transaction{}
+	for _, event := range events {
+		if event.Type == domain.EventTransactionRejected {
+			declines = append(declines, *event.Transaction)
+		}
+	}
+	sort.SliceStable(declines, func(i, j int) bool {
+		return declines[i].CreatedAt.Before(declines[j].CreatedAt)
+	})
+	return declines
+}
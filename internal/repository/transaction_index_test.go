@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unknown/authorizer/internal/core/domain"
+)
+
+func TestTransactionIndex(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenNow := givenTime.Add(24 * time.Hour)
+
+	testCases := map[string]func(*testing.T){
+		"should keep transactions added out of order in time order": func(t *testing.T) {
+			// 	given
+			index := newTransactionIndex(0)
+			index.add(domain.Transaction{ID: "t3", CreatedAt: givenTime.Add(2 * time.Minute)}, givenNow)
+			index.add(domain.Transaction{ID: "t1", CreatedAt: givenTime}, givenNow)
+			index.add(domain.Transaction{ID: "t2", CreatedAt: givenTime.Add(time.Minute)}, givenNow)
+
+			// 	when
+			transactions, complete := index.after(givenTime)
+
+			// 	then
+			assert.True(t, complete)
+			assert.Equal(t, []domain.Transaction{
+				{ID: "t2", CreatedAt: givenTime.Add(time.Minute)},
+				{ID: "t3", CreatedAt: givenTime.Add(2 * time.Minute)},
+			}, transactions)
+		},
+		"should keep transactions made at the same time in insertion order": func(t *testing.T) {
+			// 	given
+			index := newTransactionIndex(0)
+			index.add(domain.Transaction{ID: "t1", CreatedAt: givenTime}, givenNow)
+			index.add(domain.Transaction{ID: "t2", CreatedAt: givenTime}, givenNow)
+
+			// 	when
+			i, found := index.find("t2")
+
+			// 	then
+			assert.True(t, found)
+			assert.Equal(t, 1, i)
+			assert.Equal(t, "t1", index.transactions[0].ID)
+		},
+		"should evict transactions past the retention": func(t *testing.T) {
+			// 	given
+			index := newTransactionIndex(time.Hour)
+			index.add(domain.Transaction{ID: "t1", CreatedAt: givenTime}, givenNow)
+			index.add(domain.Transaction{ID: "t2", CreatedAt: givenTime.Add(30 * time.Minute)}, givenNow)
+
+			// 	when
+			index.add(domain.Transaction{ID: "t3", CreatedAt: givenTime.Add(2 * time.Hour)}, givenNow)
+
+			// 	then
+			assert.Len(t, index.transactions, 1)
+			assert.False(t, index.complete())
+			_, found := index.find("t1")
+			assert.False(t, found)
+			_, found = index.find("t3")
+			assert.True(t, found)
+		},
+		"should not index transaction already past the retention": func(t *testing.T) {
+			// 	given
+			index := newTransactionIndex(time.Hour)
+			index.add(domain.Transaction{ID: "t1", CreatedAt: givenTime.Add(2 * time.Hour)}, givenNow)
+			index.add(domain.Transaction{ID: "t2", CreatedAt: givenTime.Add(3 * time.Hour)}, givenNow)
+
+			// 	when
+			index.add(domain.Transaction{ID: "t3", CreatedAt: givenTime}, givenNow)
+
+			// 	then
+			assert.Len(t, index.transactions, 2)
+			_, found := index.find("t3")
+			assert.False(t, found)
+		},
+		"should tell lookups reaching past the retention are not complete": func(t *testing.T) {
+			// 	given
+			index := newTransactionIndex(time.Hour)
+			index.add(domain.Transaction{ID: "t1", CreatedAt: givenTime}, givenNow)
+			index.add(domain.Transaction{ID: "t2", CreatedAt: givenTime.Add(2 * time.Hour)}, givenNow)
+
+			// 	when
+			_, recentComplete := index.after(givenTime.Add(time.Hour))
+			_, pastComplete := index.after(givenTime)
+
+			// 	then
+			assert.True(t, recentComplete)
+			assert.False(t, pastComplete)
+		},
+		"should not evict transactions before one dated in the future": func(t *testing.T) {
+			// 	given
+			index := newTransactionIndex(time.Hour)
+			index.add(domain.Transaction{ID: "t1", CreatedAt: givenTime}, givenTime)
+
+			// 	when
+			index.add(domain.Transaction{ID: "t2", CreatedAt: givenTime.AddDate(80, 0, 0)}, givenTime.Add(time.Minute))
+			index.add(domain.Transaction{ID: "t3", CreatedAt: givenTime.Add(time.Minute)}, givenTime.Add(time.Minute))
+
+			// 	then
+			_, complete := index.after(givenTime.Add(-time.Minute))
+			assert.True(t, complete)
+			_, found := index.find("t1")
+			assert.True(t, found)
+			_, found = index.find("t2")
+			assert.True(t, found)
+		},
+	}
+
+	for name, run := range testCases {
+		t.Run(name, run)
+	}
+}
+
+func TestLedgerTransactions(t *testing.T) {
+	givenTime := time.Date(2019, 02, 13, 10, 0, 0, 0, time.UTC)
+	givenTransactions := []domain.Transaction{
+		{ID: "t1", AccountID: "1", Money: domain.Money{Amount: 10}, CreatedAt: givenTime.Add(time.Minute)},
+		{ID: "t2", AccountID: "1", Money: domain.Money{Amount: 20}, CreatedAt: givenTime},
+	}
+
+	// 	when
+	transactions := ledgerTransactions([]domain.Event{
+		domain.NewAccountCreated(domain.Account{ID: "1"}, givenTime),
+		domain.NewTransactionAuthorized(givenTransactions[0], givenTime),
+		domain.NewTransactionAuthorized(givenTransactions[1], givenTime),
+		domain.NewTransactionReversed(givenTransactions[0], domain.Reversal{AccountID: "1", TransactionID: "t1"}, givenTime),
+	})
+
+	// 	then
+	reversedTransaction := givenTransactions[0]
+	reversedTransaction.Reversed = true
+	assert.Equal(t, []domain.Transaction{givenTransactions[1], reversedTransaction}, transactions)
+}